@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// A scheduled job in the heap. Only jobs with a next match are in the heap.
+type entry struct {
+	job   *job
+	next  time.Time
+	index int
+}
+
+// Implements container/heap, ordered by the next match of each job.
+type jobHeap []*entry
+
+func (h jobHeap) Len() int {
+	return len(h)
+}
+
+func (h jobHeap) Less(i, j int) bool {
+	return h[i].next.Before(h[j].next)
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*h = old[:len(old)-1]
+	return e
+}
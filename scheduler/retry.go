@@ -0,0 +1,138 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/DonGar/go-timeglob/timeglob"
+	"time"
+)
+
+// Options which control a single job. The zero value runs each match once,
+// without a timeout.
+type JobOptions struct {
+	// If set, the context passed to each attempt is done after this long.
+	Timeout time.Duration
+
+	// How to retry failed attempts.
+	Retry RetryPolicy
+}
+
+// How to retry a job which returns an error. Retries never start at or after
+// the job's next match, and the context of a retry is done at the next match,
+// so a failing job can't delay its own schedule.
+type RetryPolicy struct {
+	// The most attempts for each match, including the first. 0 or 1 means no
+	// retries.
+	MaxAttempts int
+
+	// How long to wait before the first retry. This doubles for each retry
+	// after that.
+	Backoff time.Duration
+
+	// If set, the longest wait between retries.
+	MaxBackoff time.Duration
+}
+
+// A context which is done at a deadline measured by the Scheduler's clock,
+// instead of the time package, so timeouts work with fake clocks.
+type deadlineContext struct {
+	context.Context
+	deadline time.Time
+}
+
+func (s *Scheduler) runMatch(j *job, scheduled time.Time) error {
+	// Run the job for a single match, with retries. Returns the error from the
+	// last attempt.
+
+	ctx := context.WithValue(s.ctx, scheduledKey{}, scheduled)
+	retry := j.options.Retry
+
+	// Retries must finish before this.
+	limit := j.sched.Next(s.clock.Now())
+
+	backoff := retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := s.attempt(ctx, j, limit, attempt > 1)
+		if err == nil || attempt >= retry.MaxAttempts {
+			return err
+		}
+
+		if limit != timeglob.UNKNOWN && !s.clock.Now().Add(backoff).Before(limit) {
+			return err
+		}
+
+		if !s.sleep(backoff) {
+			return err
+		}
+
+		backoff *= 2
+		if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
+
+func (s *Scheduler) attempt(ctx context.Context, j *job, limit time.Time, retry bool) error {
+	// Call the job once, with a deadline for its timeout, or the next match
+	// if this is a retry.
+
+	deadline := timeglob.UNKNOWN
+	if j.options.Timeout > 0 {
+		deadline = s.clock.Now().Add(j.options.Timeout)
+	}
+	if retry && limit != timeglob.UNKNOWN && (deadline == timeglob.UNKNOWN || limit.Before(deadline)) {
+		deadline = limit
+	}
+
+	if deadline != timeglob.UNKNOWN {
+		var cancel context.CancelFunc
+		ctx, cancel = s.withDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	return j.fn(ctx)
+}
+
+func (s *Scheduler) sleep(d time.Duration) bool {
+	// Wait for d on the Scheduler's clock. Returns false if the Scheduler was
+	// stopped first.
+
+	wake := make(chan struct{})
+	timer := s.clock.AfterFunc(d, func() { close(wake) })
+	defer timer.Stop()
+
+	select {
+	case <-wake:
+		return true
+	case <-s.stopping:
+		return false
+	}
+}
+
+func (s *Scheduler) withDeadline(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	// Like context.WithDeadline, using the Scheduler's clock.
+
+	ctx, cancel := context.WithCancelCause(parent)
+	timer := s.clock.AfterFunc(deadline.Sub(s.clock.Now()), func() {
+		cancel(context.DeadlineExceeded)
+	})
+
+	return &deadlineContext{ctx, deadline}, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+func (c *deadlineContext) Deadline() (time.Time, bool) {
+	if parent, ok := c.Context.Deadline(); ok && parent.Before(c.deadline) {
+		return parent, true
+	}
+	return c.deadline, true
+}
+
+func (c *deadlineContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && context.Cause(c.Context) == context.DeadlineExceeded {
+		return context.DeadlineExceeded
+	}
+	return err
+}
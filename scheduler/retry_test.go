@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/DonGar/go-timeglob/timeglob/timeglobtest"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+// Reports a single attempt of a job.
+type attempt struct {
+	now      time.Time
+	deadline time.Time
+}
+
+func waitPending(clock *timeglobtest.FakeClock, n int) {
+	// Wait until a job's goroutine has created its timers.
+	for clock.Pending() != n {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func attemptRecorder(clock *timeglobtest.FakeClock, attempts chan attempt, failures int) JobFunc {
+	// A job which fails the given number of times, and then succeeds.
+	return func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		attempts <- attempt{clock.Now(), deadline}
+
+		if failures > 0 {
+			failures--
+			return fmt.Errorf("Failed")
+		}
+		return nil
+	}
+}
+
+func (suite *MySuite) TestSchedulerRetry(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	errs := make(chan error, 10)
+	s := New(Options{Clock: clock, OnError: func(name string, err error) { errs <- err }})
+	attempts := make(chan attempt, 10)
+
+	c.Assert(s.AddWithOptions("hourly", parse(c, "*:0 UTC"), attemptRecorder(clock, attempts, 3), JobOptions{
+		Retry: RetryPolicy{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: 3 * time.Minute},
+	}), check.IsNil)
+	s.Start()
+
+	// Retries back off exponentially, up to the maximum, and end at the next
+	// match.
+	clock.Advance(time.Hour)
+	c.Check(<-attempts, check.Equals, attempt{start.Add(time.Hour), time.Time{}})
+
+	for _, wait := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		waitPending(clock, 2)
+		clock.Advance(wait)
+		c.Check(<-attempts, check.Equals, attempt{clock.Now(), start.Add(2 * time.Hour)})
+	}
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+	c.Check(len(errs), check.Equals, 0)
+	c.Check(s.List()[0].LastError, check.IsNil)
+}
+
+func (suite *MySuite) TestSchedulerRetryNextMatch(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	errs := make(chan error, 10)
+	s := New(Options{Clock: clock, OnError: func(name string, err error) { errs <- err }})
+	attempts := make(chan attempt, 10)
+
+	c.Assert(s.AddWithOptions("hourly", parse(c, "*:0 UTC"), attemptRecorder(clock, attempts, 100), JobOptions{
+		Retry: RetryPolicy{MaxAttempts: 10, Backoff: 20 * time.Minute},
+	}), check.IsNil)
+	s.Start()
+
+	// The second retry would start at 2:00, so it's not attempted.
+	clock.Advance(time.Hour)
+	c.Check((<-attempts).now, check.Equals, start.Add(time.Hour))
+	waitPending(clock, 2)
+	clock.Advance(20 * time.Minute)
+	c.Check((<-attempts).now, check.Equals, start.Add(80*time.Minute))
+
+	c.Check(<-errs, check.ErrorMatches, "Failed")
+	waitIdle(s)
+
+	// The next match runs as usual.
+	clock.Advance(40 * time.Minute)
+	c.Check((<-attempts).now, check.Equals, start.Add(2*time.Hour))
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+}
+
+func (suite *MySuite) TestSchedulerTimeout(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	errs := make(chan error, 10)
+	s := New(Options{Clock: clock, OnError: func(name string, err error) { errs <- err }})
+
+	started := make(chan time.Time, 10)
+	c.Assert(s.AddWithOptions("hourly", parse(c, "*:0 UTC"), func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		c.Check(ok, check.Equals, true)
+		started <- deadline
+
+		<-ctx.Done()
+		return ctx.Err()
+	}, JobOptions{Timeout: 5 * time.Minute}), check.IsNil)
+	s.Start()
+
+	clock.Advance(time.Hour)
+	c.Check(<-started, check.Equals, start.Add(65*time.Minute))
+
+	waitPending(clock, 2)
+	clock.Advance(5 * time.Minute)
+	c.Check(<-errs, check.Equals, context.DeadlineExceeded)
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+}
+
+func (suite *MySuite) TestSchedulerStopRetry(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	s := New(Options{Clock: clock})
+	attempts := make(chan attempt, 10)
+
+	c.Assert(s.AddWithOptions("daily", parse(c, "0:00 UTC"), attemptRecorder(clock, attempts, 100), JobOptions{
+		Retry: RetryPolicy{MaxAttempts: 10, Backoff: time.Hour},
+	}), check.IsNil)
+	s.Start()
+
+	clock.Advance(24 * time.Hour)
+	<-attempts
+	waitPending(clock, 2)
+
+	// Stop doesn't wait for the backoff.
+	c.Check(s.Stop(context.Background()), check.IsNil)
+	c.Check(len(attempts), check.Equals, 0)
+}
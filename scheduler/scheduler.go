@@ -0,0 +1,504 @@
+// Package scheduler runs named jobs on the matches of TimeGlobs, or other
+// timeglob.Schedules, using a single timer for all of them.
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"github.com/DonGar/go-timeglob/timeglob"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The work done by a job. The context is cancelled if the Scheduler is forced
+// to stop while the job is running.
+type JobFunc func(ctx context.Context) error
+
+// Describes a job, as returned by List.
+type JobInfo struct {
+	Name     string
+	Schedule timeglob.Schedule
+
+	// The same as Schedule if it's a TimeGlob, otherwise nil.
+	Glob *timeglob.TimeGlob
+
+	// The next time the job will run, or timeglob.UNKNOWN if it's not
+	// scheduled.
+	Next time.Time
+
+	// Is the job running now?
+	Running bool
+
+	// When the most recent run started, and the error from the most recent run
+	// which finished.
+	LastRun   time.Time
+	LastError error
+}
+
+// What a Scheduler does with matches which were missed before it started,
+// according to its StateStore.
+type CatchUpPolicy int
+
+const (
+	// Ignore missed matches. This is the default.
+	NO_CATCH_UP CatchUpPolicy = iota
+
+	// Run once, for the latest missed match.
+	CATCH_UP_ONCE
+
+	// Run once for each missed match, in order.
+	CATCH_UP_EACH
+)
+
+// Options which control a Scheduler. The zero value is ready to use.
+type Options struct {
+	// Source of the current time, and timers. If nil, timeglob.RealClock is
+	// used.
+	Clock timeglob.Clock
+
+	// If not nil, called with the job name each time a run, or reading the
+	// Store returns an error. Called from the job's goroutine, or from Start
+	// or Add for Store errors, without holding any locks, so it may use the
+	// Scheduler.
+	OnError func(name string, err error)
+
+	// If not nil, records successful runs, and is used to find matches which
+	// were missed before the Scheduler started.
+	Store StateStore
+
+	// How to handle missed matches. Only used with a Store. Jobs which have
+	// never succeeded have nothing to catch up.
+	CatchUp CatchUpPolicy
+
+	// Ignore missed matches which are older than this. 0 means no limit.
+	LookBack time.Duration
+}
+
+// The context key for the match time of a run.
+type scheduledKey struct{}
+
+type job struct {
+	name    string
+	sched   timeglob.Schedule
+	fn      JobFunc
+	options JobOptions
+
+	// The job's place in the heap, or nil if it has no next match.
+	entry *entry
+
+	running   bool
+	lastRun   time.Time
+	lastError error
+}
+
+type Scheduler struct {
+	lock     sync.Mutex
+	clock    timeglob.Clock
+	onError  func(name string, err error)
+	store    StateStore
+	catchUp  CatchUpPolicy
+	lookBack time.Duration
+	jobs     map[string]*job
+	heap     jobHeap
+	timer    timeglob.Timer
+	started  bool
+	stopped  bool
+
+	// Passed to jobs, and cancelled by Stop.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+
+	// Closed by Stop, to end retries early.
+	stopping chan struct{}
+}
+
+func New(options Options) *Scheduler {
+	// Create a Scheduler. Jobs don't run until Start is called.
+
+	clock := options.Clock
+	if clock == nil {
+		clock = timeglob.RealClock
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Scheduler{
+		clock:    clock,
+		onError:  options.OnError,
+		store:    options.Store,
+		catchUp:  options.CatchUp,
+		lookBack: options.LookBack,
+		jobs:     map[string]*job{},
+		ctx:      ctx,
+		cancel:   cancel,
+		stopping: make(chan struct{}),
+	}
+}
+
+func Scheduled(ctx context.Context) (time.Time, bool) {
+	// Return the match time a job is running for, from the context passed to
+	// the job.
+	t, ok := ctx.Value(scheduledKey{}).(time.Time)
+	return t, ok
+}
+
+func (s *Scheduler) Add(name string, sched timeglob.Schedule, fn JobFunc) error {
+	// Register a job to run on each match of sched, which is usually a
+	// TimeGlob. Names must be unique. If a run is still going at the next
+	// match, that match is skipped.
+	return s.AddWithOptions(name, sched, fn, JobOptions{})
+}
+
+func (s *Scheduler) AddWithOptions(name string, sched timeglob.Schedule, fn JobFunc, options JobOptions) error {
+	// Same as Add, with a timeout and retries for the job.
+
+	s.lock.Lock()
+
+	if s.stopped {
+		s.lock.Unlock()
+		return fmt.Errorf("Scheduler is stopped")
+	}
+
+	if _, ok := s.jobs[name]; ok {
+		s.lock.Unlock()
+		return fmt.Errorf("Job already exists: %s", name)
+	}
+
+	j := &job{name: name, sched: sched, fn: fn, options: options}
+	s.jobs[name] = j
+	started := s.started
+	s.lock.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	// Read the store without the lock, since it may be slow, and errors go
+	// to OnError, which may use the Scheduler.
+	last, ok := s.lastRun(j)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The job may have been removed, or the Scheduler stopped meanwhile.
+	if s.stopped || s.jobs[name] != j {
+		return nil
+	}
+
+	now := s.clock.Now()
+	if ok {
+		s.catchUpMissed(j, now, last)
+	}
+	s.schedule(j, now)
+	s.arm(now)
+
+	return nil
+}
+
+func (s *Scheduler) Remove(name string) bool {
+	// Remove a job, so it won't run again. A run in progress is not
+	// interrupted. Returns false if there is no such job.
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	j, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+
+	delete(s.jobs, name)
+	if j.entry != nil {
+		heap.Remove(&s.heap, j.entry.index)
+		j.entry = nil
+	}
+
+	return true
+}
+
+func (s *Scheduler) List() []JobInfo {
+	// Describe all jobs, sorted by name.
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := []JobInfo{}
+	for _, j := range s.jobs {
+		next := timeglob.UNKNOWN
+		if j.entry != nil {
+			next = j.entry.next
+		}
+
+		glob, _ := j.sched.(*timeglob.TimeGlob)
+
+		result = append(result, JobInfo{
+			Name:      j.name,
+			Schedule:  j.sched,
+			Glob:      glob,
+			Next:      next,
+			Running:   j.running,
+			LastRun:   j.lastRun,
+			LastError: j.lastError,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func (s *Scheduler) Start() {
+	// Start running jobs. Does nothing if the Scheduler was already started,
+	// or has been stopped.
+
+	s.lock.Lock()
+	if s.started || s.stopped {
+		s.lock.Unlock()
+		return
+	}
+	s.started = true
+
+	jobs := []*job{}
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.lock.Unlock()
+
+	// Read the store without the lock, as in AddWithOptions. Jobs added
+	// meanwhile are scheduled by AddWithOptions itself.
+	type lastRun struct {
+		time time.Time
+		ok   bool
+	}
+	lastRuns := make([]lastRun, len(jobs))
+	for i, j := range jobs {
+		lastRuns[i].time, lastRuns[i].ok = s.lastRun(j)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	now := s.clock.Now()
+	for i, j := range jobs {
+		if s.jobs[j.name] != j {
+			continue
+		}
+		if lastRuns[i].ok {
+			s.catchUpMissed(j, now, lastRuns[i].time)
+		}
+		s.schedule(j, now)
+	}
+	s.arm(now)
+}
+
+func (s *Scheduler) Stop(ctx context.Context) error {
+	// Stop starting new runs, and wait for running jobs to finish. If ctx is
+	// done first, the context passed to running jobs is cancelled, and
+	// ctx.Err() is returned without waiting any longer. A stopped Scheduler
+	// can't be restarted.
+
+	s.lock.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stopping)
+
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+
+		for _, e := range s.heap {
+			e.job.entry = nil
+		}
+		s.heap = nil
+	}
+	s.lock.Unlock()
+
+	// No run can start once stopped is set, so it's safe to Wait.
+	finished := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(finished)
+	}()
+
+	defer s.cancel()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) schedule(j *job, now time.Time) {
+	// Put a job in the heap for its next match after now. Jobs without a next
+	// match are left out. Must hold the lock.
+
+	next := j.sched.Next(now)
+	if next == timeglob.UNKNOWN {
+		return
+	}
+
+	j.entry = &entry{job: j, next: next}
+	heap.Push(&s.heap, j.entry)
+}
+
+func (s *Scheduler) arm(now time.Time) {
+	// Arm the timer for the earliest match in the heap. As with Ticker, the
+	// timer wakes at least every timeglob.DEFAULT_RECHECK_INTERVAL, in case
+	// the wall clock has jumped. Must hold the lock.
+
+	if len(s.heap) == 0 {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		return
+	}
+
+	d := s.heap[0].next.Sub(now)
+	if d > timeglob.DEFAULT_RECHECK_INTERVAL {
+		d = timeglob.DEFAULT_RECHECK_INTERVAL
+	}
+
+	if s.timer == nil {
+		s.timer = s.clock.AfterFunc(d, s.wake)
+	} else {
+		s.timer.Reset(d)
+	}
+}
+
+func (s *Scheduler) wake() {
+	// Called by the timer. Start every job which is due, and reschedule it.
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Stop may have raced with the timer firing.
+	if s.stopped {
+		return
+	}
+
+	now := s.clock.Now()
+	for len(s.heap) > 0 && !now.Before(s.heap[0].next) {
+		e := heap.Pop(&s.heap).(*entry)
+		j := e.job
+		j.entry = nil
+
+		s.run(j, now, func(fn func(time.Time) bool) {
+			fn(e.next)
+		})
+		s.schedule(j, now)
+	}
+
+	s.arm(now)
+}
+
+func (s *Scheduler) lastRun(j *job) (time.Time, bool) {
+	// Read the last time the job succeeded from the store, if catching up
+	// needs it. Errors are reported, and treated as no last run. Must not
+	// hold the lock.
+
+	if s.store == nil || s.catchUp == NO_CATCH_UP {
+		return time.Time{}, false
+	}
+
+	last, ok, err := s.store.LastRun(j.name)
+	if err != nil {
+		s.reportError(j.name, err)
+		return time.Time{}, false
+	}
+	return last, ok
+}
+
+func (s *Scheduler) catchUpMissed(j *job, now, last time.Time) {
+	// Catch up on matches missed since the job last succeeded at last. Must
+	// hold the lock.
+
+	// Matches after the last run, through now.
+	from := last.Add(time.Nanosecond)
+	if s.lookBack > 0 && from.Before(now.Add(-s.lookBack)) {
+		from = now.Add(-s.lookBack)
+	}
+	to := now.Add(time.Nanosecond)
+
+	if s.catchUp == CATCH_UP_ONCE {
+		latest := j.sched.Prev(now)
+		if latest == timeglob.UNKNOWN || latest.Before(from) {
+			return
+		}
+		s.run(j, now, func(fn func(time.Time) bool) {
+			fn(latest)
+		})
+		return
+	}
+
+	if timeglob.ScheduleCount(j.sched, from, to) == 0 {
+		return
+	}
+	s.run(j, now, func(fn func(time.Time) bool) {
+		timeglob.ScheduleBetweenFunc(j.sched, from, to, fn)
+	})
+}
+
+func (s *Scheduler) run(j *job, now time.Time, matches func(func(time.Time) bool)) {
+	// Start running the job in its own goroutine, once for each match passed
+	// to fn by matches, unless it's already running. Must hold the lock.
+
+	if j.running {
+		return
+	}
+
+	j.running = true
+	j.lastRun = now
+	s.running.Add(1)
+
+	go func() {
+		defer s.running.Done()
+
+		var err error
+		matches(func(scheduled time.Time) bool {
+			err = s.runMatch(j, scheduled)
+			s.finished(j, scheduled, err)
+
+			// Stop catching up if the Scheduler is stopping.
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			return !s.stopped
+		})
+
+		s.lock.Lock()
+		j.running = false
+		j.lastError = err
+		s.lock.Unlock()
+	}()
+}
+
+func (s *Scheduler) finished(j *job, scheduled time.Time, err error) {
+	// Record the result of a single run.
+
+	if err != nil {
+		s.reportError(j.name, err)
+		return
+	}
+
+	if s.store != nil {
+		if err := s.store.SetLastRun(j.name, scheduled); err != nil {
+			s.reportError(j.name, err)
+		}
+	}
+}
+
+func (s *Scheduler) reportError(name string, err error) {
+	if s.onError != nil {
+		s.onError(name, err)
+	}
+}
@@ -0,0 +1,405 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/DonGar/go-timeglob/timeglob"
+	"github.com/DonGar/go-timeglob/timeglob/timeglobtest"
+	"gopkg.in/check.v1"
+	"testing"
+	"time"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MySuite struct{}
+
+var _ = check.Suite(&MySuite{})
+
+var start = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func parse(c *check.C, glob string) *timeglob.TimeGlob {
+	tg, err := timeglob.Parse(glob)
+	c.Assert(err, check.IsNil)
+	return tg
+}
+
+func recorder(runs chan string, name string) JobFunc {
+	// A job which reports each run on runs.
+	return func(ctx context.Context) error {
+		runs <- name
+		return nil
+	}
+}
+
+func receiveRuns(c *check.C, runs chan string, expected ...string) {
+	// Validate that exactly the expected runs happened, in any order.
+
+	received := map[string]int{}
+	for range expected {
+		select {
+		case name := <-runs:
+			received[name]++
+		case <-time.After(10 * time.Second):
+			c.Fatalf("Missing runs, got %v, expected %v", received, expected)
+		}
+	}
+
+	wanted := map[string]int{}
+	for _, name := range expected {
+		wanted[name]++
+	}
+	c.Check(received, check.DeepEquals, wanted)
+
+	select {
+	case name := <-runs:
+		c.Errorf("Unexpected run %s", name)
+	default:
+	}
+}
+
+func (suite *MySuite) TestSchedulerRuns(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	s := New(Options{Clock: clock})
+	runs := make(chan string, 10)
+
+	c.Check(s.Add("hourly", parse(c, "*:0 UTC"), recorder(runs, "hourly")), check.IsNil)
+	c.Check(s.Add("half", parse(c, "*:0,30 UTC"), recorder(runs, "half")), check.IsNil)
+
+	// Nothing runs before Start.
+	clock.Advance(time.Hour)
+	receiveRuns(c, runs)
+
+	s.Start()
+
+	// A single timer serves every job.
+	c.Check(clock.Pending(), check.Equals, 1)
+
+	clock.Advance(30 * time.Minute)
+	receiveRuns(c, runs, "half")
+
+	clock.Advance(30 * time.Minute)
+	receiveRuns(c, runs, "hourly", "half")
+
+	// Jobs added after Start are scheduled right away.
+	c.Check(s.Add("quarter", parse(c, "*:15 UTC"), recorder(runs, "quarter")), check.IsNil)
+	clock.Advance(15 * time.Minute)
+	receiveRuns(c, runs, "quarter")
+
+	c.Check(s.Remove("half"), check.Equals, true)
+	c.Check(s.Remove("half"), check.Equals, false)
+	clock.Advance(45 * time.Minute)
+	receiveRuns(c, runs, "hourly")
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+	c.Check(clock.Pending(), check.Equals, 0)
+
+	clock.Advance(time.Hour)
+	receiveRuns(c, runs)
+}
+
+func (suite *MySuite) TestSchedulerAddErrors(c *check.C) {
+	s := New(Options{Clock: timeglobtest.NewFakeClock(start)})
+	tg := parse(c, "*:0 UTC")
+	fn := func(ctx context.Context) error { return nil }
+
+	c.Check(s.Add("a", tg, fn), check.IsNil)
+	c.Check(s.Add("a", tg, fn), check.ErrorMatches, "Job already exists: a")
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+	c.Check(s.Add("b", tg, fn), check.ErrorMatches, "Scheduler is stopped")
+}
+
+func (suite *MySuite) TestSchedulerList(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	errs := make(chan string, 10)
+	s := New(Options{
+		Clock: clock,
+		OnError: func(name string, err error) {
+			errs <- fmt.Sprintf("%s: %s", name, err)
+		},
+	})
+
+	hourly := parse(c, "*:0 UTC")
+	never := parse(c, "2015/1/1 0:00 UTC")
+	c.Assert(s.Add("hourly", hourly, func(ctx context.Context) error {
+		return fmt.Errorf("Failed")
+	}), check.IsNil)
+	c.Assert(s.Add("expired", never, func(ctx context.Context) error {
+		return nil
+	}), check.IsNil)
+
+	s.Start()
+	c.Check(s.List(), check.DeepEquals, []JobInfo{
+		{Name: "expired", Schedule: never, Glob: never, Next: timeglob.UNKNOWN},
+		{Name: "hourly", Schedule: hourly, Glob: hourly, Next: start.Add(time.Hour)},
+	})
+
+	clock.Advance(time.Hour)
+	c.Check(<-errs, check.Equals, "hourly: Failed")
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+	c.Check(s.List(), check.DeepEquals, []JobInfo{
+		{Name: "expired", Schedule: never, Glob: never, Next: timeglob.UNKNOWN},
+		{Name: "hourly", Schedule: hourly, Glob: hourly, Next: timeglob.UNKNOWN,
+			LastRun: start.Add(time.Hour), LastError: fmt.Errorf("Failed")},
+	})
+}
+
+func (suite *MySuite) TestSchedulerSkipOverlap(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	s := New(Options{Clock: clock})
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	c.Assert(s.Add("slow", parse(c, "*:*:0 UTC"), func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}), check.IsNil)
+
+	s.Start()
+	clock.Advance(time.Minute)
+	<-started
+
+	// Still running, so these matches are skipped.
+	clock.Advance(2 * time.Minute)
+	select {
+	case <-started:
+		c.Error("Overlapping run")
+	default:
+	}
+
+	close(release)
+	c.Check(s.Stop(context.Background()), check.IsNil)
+}
+
+func (suite *MySuite) TestSchedulerStopWaits(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	s := New(Options{Clock: clock})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	finished := false
+	c.Assert(s.Add("slow", parse(c, "*:0 UTC"), func(ctx context.Context) error {
+		close(started)
+		<-release
+		finished = true
+		return nil
+	}), check.IsNil)
+
+	s.Start()
+	clock.Advance(time.Hour)
+	<-started
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- s.Stop(context.Background())
+	}()
+
+	select {
+	case <-stopped:
+		c.Fatal("Stop returned while the job was running")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	c.Check(<-stopped, check.IsNil)
+	c.Check(finished, check.Equals, true)
+}
+
+func (suite *MySuite) TestSchedulerStopTimeout(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	s := New(Options{Clock: clock})
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	c.Assert(s.Add("stuck", parse(c, "*:0 UTC"), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}), check.IsNil)
+
+	s.Start()
+	clock.Advance(time.Hour)
+	<-started
+
+	// The job's context is cancelled once the Stop context expires.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Check(s.Stop(ctx), check.Equals, context.Canceled)
+	<-cancelled
+}
+
+func catchUp(c *check.C, policy CatchUpPolicy, lookBack time.Duration) ([]time.Time, *MemoryStore) {
+	// Start a Scheduler with an hourly job, which last ran at midnight, and
+	// return the match times of the runs which catch up.
+
+	store := NewMemoryStore()
+	c.Assert(store.SetLastRun("hourly", start), check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(start.Add(3*time.Hour + 30*time.Minute))
+	s := New(Options{Clock: clock, Store: store, CatchUp: policy, LookBack: lookBack})
+
+	scheduled := []time.Time{}
+	c.Assert(s.Add("hourly", parse(c, "*:0 UTC"), func(ctx context.Context) error {
+		t, ok := Scheduled(ctx)
+		c.Check(ok, check.Equals, true)
+		scheduled = append(scheduled, t)
+		return nil
+	}), check.IsNil)
+
+	// Catching up stops if the Scheduler is stopped, so wait for it first.
+	s.Start()
+	waitIdle(s)
+	c.Check(s.Stop(context.Background()), check.IsNil)
+	return scheduled, store
+}
+
+func waitIdle(s *Scheduler) {
+	// Wait until no jobs are running.
+	for {
+		running := false
+		for _, info := range s.List() {
+			running = running || info.Running
+		}
+		if !running {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (suite *MySuite) TestSchedulerCatchUp(c *check.C) {
+	scheduled, _ := catchUp(c, NO_CATCH_UP, 0)
+	c.Check(scheduled, check.DeepEquals, []time.Time{})
+
+	scheduled, store := catchUp(c, CATCH_UP_ONCE, 0)
+	c.Check(scheduled, check.DeepEquals, []time.Time{start.Add(3 * time.Hour)})
+
+	last, _, _ := store.LastRun("hourly")
+	c.Check(last, check.Equals, start.Add(3*time.Hour))
+
+	scheduled, _ = catchUp(c, CATCH_UP_EACH, 0)
+	c.Check(scheduled, check.DeepEquals, []time.Time{
+		start.Add(1 * time.Hour),
+		start.Add(2 * time.Hour),
+		start.Add(3 * time.Hour),
+	})
+
+	// Missed matches before the look back are ignored.
+	scheduled, _ = catchUp(c, CATCH_UP_EACH, 90*time.Minute)
+	c.Check(scheduled, check.DeepEquals, []time.Time{
+		start.Add(2 * time.Hour),
+		start.Add(3 * time.Hour),
+	})
+
+	scheduled, _ = catchUp(c, CATCH_UP_ONCE, 10*time.Minute)
+	c.Check(scheduled, check.DeepEquals, []time.Time{})
+}
+
+// A StateStore which can't be read.
+type brokenStore struct{}
+
+func (brokenStore) LastRun(name string) (time.Time, bool, error) {
+	return time.Time{}, false, fmt.Errorf("Broken")
+}
+
+func (brokenStore) SetLastRun(name string, scheduled time.Time) error {
+	return fmt.Errorf("Broken")
+}
+
+func (suite *MySuite) TestSchedulerStoreErrors(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+
+	// OnError can use the Scheduler, since it isn't called with the lock held.
+	var s *Scheduler
+	errors := []string{}
+	s = New(Options{
+		Clock:   clock,
+		Store:   brokenStore{},
+		CatchUp: CATCH_UP_EACH,
+		OnError: func(name string, err error) {
+			errors = append(errors, fmt.Sprintf("%s: %s %d", name, err, len(s.List())))
+		},
+	})
+
+	c.Assert(s.Add("a", parse(c, "*:0 UTC"), recorder(nil, "a")), check.IsNil)
+	s.Start()
+	c.Assert(s.Add("b", parse(c, "*:0 UTC"), recorder(nil, "b")), check.IsNil)
+	c.Check(errors, check.DeepEquals, []string{"a: Broken 1", "b: Broken 2"})
+
+	// Jobs are still scheduled.
+	for _, info := range s.List() {
+		c.Check(info.Next, check.Equals, start.Add(time.Hour))
+	}
+	c.Check(s.Stop(context.Background()), check.IsNil)
+}
+
+func (suite *MySuite) TestSchedulerRecordsRuns(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	store := NewMemoryStore()
+	s := New(Options{Clock: clock, Store: store, CatchUp: CATCH_UP_EACH})
+
+	fail := true
+	ran := make(chan struct{}, 10)
+	c.Assert(s.Add("hourly", parse(c, "*:0 UTC"), func(ctx context.Context) error {
+		defer func() { ran <- struct{}{} }()
+		if fail {
+			return fmt.Errorf("Failed")
+		}
+		return nil
+	}), check.IsNil)
+
+	// Never succeeded, so nothing to catch up, or record.
+	s.Start()
+	clock.Advance(time.Hour)
+	<-ran
+	_, ok, _ := store.LastRun("hourly")
+	c.Check(ok, check.Equals, false)
+	c.Check(s.Stop(context.Background()), check.IsNil)
+
+	// Successful runs are recorded with their match time.
+	s = New(Options{Clock: clock, Store: store})
+	fail = false
+	c.Assert(s.Add("hourly", parse(c, "*:0 UTC"), func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}), check.IsNil)
+	s.Start()
+	clock.Advance(time.Hour + time.Minute)
+	<-ran
+	c.Check(s.Stop(context.Background()), check.IsNil)
+
+	last, ok, _ := store.LastRun("hourly")
+	c.Check(ok, check.Equals, true)
+	c.Check(last, check.Equals, start.Add(2*time.Hour))
+}
+
+func (suite *MySuite) TestSchedulerSchedule(c *check.C) {
+	clock := timeglobtest.NewFakeClock(start)
+	s := New(Options{Clock: clock})
+	runs := make(chan string, 10)
+
+	// Any timeglob.Schedule can drive a job.
+	r, err := timeglob.ParseRepeatingInterval("R/2016-01-01T00:00:00Z/PT90M")
+	c.Assert(err, check.IsNil)
+	c.Check(s.Add("interval", r, recorder(runs, "interval")), check.IsNil)
+	s.Start()
+
+	clock.Advance(time.Hour)
+	receiveRuns(c, runs)
+
+	clock.Advance(30 * time.Minute)
+	receiveRuns(c, runs, "interval")
+
+	jobs := s.List()
+	c.Assert(jobs, check.HasLen, 1)
+	c.Check(jobs[0].Schedule, check.Equals, timeglob.Schedule(r))
+	c.Check(jobs[0].Glob, check.IsNil)
+	c.Check(jobs[0].Next, check.Equals, start.Add(3*time.Hour))
+
+	c.Check(s.Stop(context.Background()), check.IsNil)
+}
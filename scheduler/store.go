@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Records the last successful run of each job, so missed matches can be
+// caught up after a restart. Implementations must be safe for concurrent use.
+type StateStore interface {
+	// Return the match time of the last successful run of a job, or false if
+	// there isn't one.
+	LastRun(name string) (time.Time, bool, error)
+
+	// Record a successful run of a job, for the given match time.
+	SetLastRun(name string, scheduled time.Time) error
+}
+
+// A StateStore which only lasts as long as the process.
+type MemoryStore struct {
+	lock    sync.Mutex
+	lastRun map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{lastRun: map[string]time.Time{}}
+}
+
+func (m *MemoryStore) LastRun(name string) (time.Time, bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	t, ok := m.lastRun[name]
+	return t, ok, nil
+}
+
+func (m *MemoryStore) SetLastRun(name string, scheduled time.Time) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.lastRun[name] = scheduled
+	return nil
+}
+
+// A StateStore which keeps a JSON object of job names and times in a file.
+// The file is replaced atomically on each update.
+type FileStore struct {
+	lock sync.Mutex
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	// The file is created by the first update, if it doesn't exist.
+	return &FileStore{path: path}
+}
+
+func (f *FileStore) LastRun(name string) (time.Time, bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	state, err := f.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	t, ok := state[name]
+	return t, ok, nil
+}
+
+func (f *FileStore) SetLastRun(name string, scheduled time.Time) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	state, err := f.read()
+	if err != nil {
+		return err
+	}
+
+	state[name] = scheduled
+	return f.write(state)
+}
+
+func (f *FileStore) read() (map[string]time.Time, error) {
+	// Read the whole file. A missing file is empty. Must hold the lock.
+
+	state := map[string]time.Time{}
+
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (f *FileStore) write(state map[string]time.Time) error {
+	// Write to a temporary file, and rename it over the original, so readers
+	// never see a partial file. Must hold the lock.
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), f.path)
+}
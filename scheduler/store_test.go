@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"gopkg.in/check.v1"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (suite *MySuite) TestMemoryStore(c *check.C) {
+	store := NewMemoryStore()
+
+	_, ok, err := store.LastRun("a")
+	c.Check(ok, check.Equals, false)
+	c.Check(err, check.IsNil)
+
+	c.Check(store.SetLastRun("a", start), check.IsNil)
+	last, ok, err := store.LastRun("a")
+	c.Check(last, check.Equals, start)
+	c.Check(ok, check.Equals, true)
+	c.Check(err, check.IsNil)
+}
+
+func (suite *MySuite) TestFileStore(c *check.C) {
+	path := filepath.Join(c.MkDir(), "state.json")
+	store := NewFileStore(path)
+
+	// A missing file is empty.
+	_, ok, err := store.LastRun("a")
+	c.Check(ok, check.Equals, false)
+	c.Check(err, check.IsNil)
+
+	c.Check(store.SetLastRun("a", start), check.IsNil)
+	c.Check(store.SetLastRun("b", start.Add(time.Hour)), check.IsNil)
+
+	// A new store reads the same file, as after a restart.
+	store = NewFileStore(path)
+	last, ok, err := store.LastRun("a")
+	c.Check(last.Equal(start), check.Equals, true)
+	c.Check(ok, check.Equals, true)
+	c.Check(err, check.IsNil)
+
+	last, ok, err = store.LastRun("b")
+	c.Check(last.Equal(start.Add(time.Hour)), check.Equals, true)
+	c.Check(ok, check.Equals, true)
+	c.Check(err, check.IsNil)
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	c.Check(err, check.IsNil)
+	c.Check(len(entries), check.Equals, 1)
+}
+
+func (suite *MySuite) TestFileStoreCorrupt(c *check.C) {
+	path := filepath.Join(c.MkDir(), "state.json")
+	c.Assert(os.WriteFile(path, []byte("not json"), 0644), check.IsNil)
+
+	store := NewFileStore(path)
+	_, _, err := store.LastRun("a")
+	c.Check(err, check.NotNil)
+	c.Check(store.SetLastRun("a", start), check.NotNil)
+}
@@ -0,0 +1,303 @@
+package timeglob
+
+import (
+	"time"
+)
+
+// A single calendar day in the location of a TimeGlob.
+type calendarDay struct {
+	year, month, day int
+
+	// Midnight at the start and end of the day.
+	start, end time.Time
+}
+
+func (tg *TimeGlob) Between(start, end time.Time) []time.Time {
+	// Find all times which match the glob and are at, or after start, and
+	// before end. Results are in order, and in the same timezone as start.
+
+	result := []time.Time{}
+	tg.BetweenFunc(start, end, func(t time.Time) bool {
+		result = append(result, t)
+		return true
+	})
+	return result
+}
+
+func (tg *TimeGlob) BetweenFunc(start, end time.Time, fn func(time.Time) bool) {
+	// Streaming version of Between. Call fn with each match in order, without
+	// building a slice. Stops early if fn returns false.
+
+	from := start.In(tg.location)
+	to := end.In(tg.location)
+
+	if !from.Before(to) {
+		return
+	}
+
+	tg.eachDay(from, to.Year(), func(cd calendarDay) bool {
+		if !cd.start.Before(to) {
+			return false
+		}
+
+		return tg.eachInDay(cd, from, func(t time.Time) bool {
+			if t.Before(from) {
+				return true
+			}
+			if !t.Before(to) {
+				return false
+			}
+			return fn(t.In(start.Location()))
+		})
+	})
+}
+
+func (tg *TimeGlob) Count(start, end time.Time) int {
+	// Count the matches which are at, or after start, and before end. Days
+	// without timezone transitions are counted arithmetically, so this is much
+	// faster than Between for dense globs and long intervals.
+
+	from := start.In(tg.location)
+	to := end.In(tg.location)
+
+	if !from.Before(to) {
+		return 0
+	}
+
+	count := 0
+	tg.eachDay(from, to.Year(), func(cd calendarDay) bool {
+		if !cd.start.Before(to) {
+			return false
+		}
+
+		count += tg.countInDay(cd, from, to)
+		return true
+	})
+
+	return count
+}
+
+func dateKey(year, month, day int) int {
+	// Return a value which sorts in the same order as the date.
+	return (year*100+month)*100 + day
+}
+
+func (tg *TimeGlob) calendarDay(year, month, day int) (calendarDay, bool) {
+	// Describe the given date in the glob's location, or return false if the
+	// date doesn't exist (IE: Feb 30), or doesn't match the glob's day and
+	// weekday.
+
+	check := time.Date(year, time.Month(month), day, 12, 0, 0, 0, time.UTC)
+	if check.Year() != year || check.Month() != time.Month(month) || check.Day() != day {
+		return calendarDay{}, false
+	}
+	if !tg.matchesDay(year, month, day) {
+		return calendarDay{}, false
+	}
+
+	return calendarDay{
+		year, month, day,
+		time.Date(year, time.Month(month), day, 0, 0, 0, 0, tg.location),
+		time.Date(year, time.Month(month), day+1, 0, 0, 0, 0, tg.location),
+	}, true
+}
+
+func (tg *TimeGlob) eachDay(from time.Time, lastYear int, fn func(calendarDay) bool) bool {
+	// Call fn with each day which matches the date portion of the glob, in
+	// order. Starts with the day containing from, and expands a year wildcard
+	// through lastYear. Returns false if fn stopped the search early.
+
+	years, months, days, _, _, _, _ := tg.expandNext(from)
+	if len(tg.year) == 0 {
+		years = intRange(from.Year(), lastYear)
+	}
+
+	fromKey := dateKey(from.Year(), int(from.Month()), from.Day())
+
+	for _, year := range years {
+		for _, month := range months {
+			for _, day := range days {
+				if dateKey(year, month, day) < fromKey {
+					continue
+				}
+
+				cd, ok := tg.calendarDay(year, month, day)
+				if !ok {
+					continue
+				}
+
+				if !fn(cd) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func (tg *TimeGlob) eachInDay(cd calendarDay, from time.Time, fn func(time.Time) bool) bool {
+	// Call fn with each match inside of a given day, in order. This follows
+	// the same rules as nextDate. Hours, minutes and seconds which end before
+	// from are skipped, but fn may still be called with some matches before
+	// from. Returns false if fn stopped early.
+
+	_, _, _, hours, minutes, seconds, milliseconds := tg.expandNext(cd.start)
+
+	for _, hour := range hours {
+		hourMinutes := minutes
+		if spanBefore(tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, 0, 0), time.Hour, from) {
+			hourMinutes = nil
+		}
+		for _, minute := range hourMinutes {
+			if spanBefore(tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, minute, 0), time.Minute, from) {
+				continue
+			}
+			for _, second := range seconds {
+				base := tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, minute, second)
+				if base == UNKNOWN || spanBefore(base, time.Second, from) {
+					continue
+				}
+				for _, millisecond := range milliseconds {
+					result := addMilliseconds(base, millisecond)
+					if result != UNKNOWN && !fn(result) {
+						return false
+					}
+				}
+			}
+		}
+
+		// Repeated hours are matched by hour wildcards, as in nextDate.
+		if len(tg.hour) == 0 {
+			base := tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, 0, 0)
+			advanced := base.Add(time.Hour)
+
+			if base.Hour() == advanced.Hour() && !spanBefore(advanced, time.Hour, from) {
+				for _, minute := range minutes {
+					for _, second := range seconds {
+						adjusted := tg.adjustMinutesSeconds(advanced, minute, second)
+						if adjusted == UNKNOWN || spanBefore(adjusted, time.Second, from) {
+							continue
+						}
+						for _, millisecond := range milliseconds {
+							result := addMilliseconds(adjusted, millisecond)
+							if result != UNKNOWN && !fn(result) {
+								return false
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func (tg *TimeGlob) regularDay(cd calendarDay) bool {
+	// Is this day exactly 24 hours long, with no timezone transitions? If so,
+	// every valid hour, minute, second combination happens exactly once.
+
+	if cd.start.Hour() != 0 || cd.end.Hour() != 0 {
+		return false
+	}
+
+	_, zoneEnd := cd.start.ZoneBounds()
+	return zoneEnd.IsZero() || !zoneEnd.Before(cd.end)
+}
+
+func clampValues(values []int, begin, end int) []int {
+	// Return the values between begin and end inclusive, or the full range
+	// for a wildcard.
+
+	if len(values) == 0 {
+		return intRange(begin, end)
+	}
+
+	result := []int{}
+	for _, v := range values {
+		if v >= begin && v <= end {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (tg *TimeGlob) clockValues() (hours, minutes, seconds, milliseconds []int) {
+	// Return the valid hours, minutes, seconds and milliseconds matched by the
+	// glob.
+	return clampValues(tg.hour, 0, 23),
+		clampValues(tg.minute, 0, 59),
+		clampValues(tg.second, 0, 59),
+		clampValues(tg.millisecond, 0, 999)
+}
+
+func (tg *TimeGlob) countBefore(offset time.Duration) int {
+	// On a regular day, count the matches less than offset after midnight.
+
+	hours, minutes, seconds, milliseconds := tg.clockValues()
+	count := 0
+
+	for _, hour := range hours {
+		hourOffset := time.Duration(hour) * time.Hour
+		if hourOffset+time.Hour <= offset {
+			count += len(minutes) * len(seconds) * len(milliseconds)
+			continue
+		}
+		if hourOffset >= offset {
+			break
+		}
+
+		for _, minute := range minutes {
+			minuteOffset := hourOffset + time.Duration(minute)*time.Minute
+			if minuteOffset+time.Minute <= offset {
+				count += len(seconds) * len(milliseconds)
+				continue
+			}
+			if minuteOffset >= offset {
+				break
+			}
+
+			for _, second := range seconds {
+				secondOffset := minuteOffset + time.Duration(second)*time.Second
+				if secondOffset+time.Second <= offset {
+					count += len(milliseconds)
+					continue
+				}
+				if secondOffset >= offset {
+					break
+				}
+
+				for _, millisecond := range milliseconds {
+					if secondOffset+time.Duration(millisecond)*time.Millisecond >= offset {
+						break
+					}
+					count++
+				}
+			}
+		}
+	}
+
+	return count
+}
+
+func (tg *TimeGlob) countInDay(cd calendarDay, from, to time.Time) int {
+	// Count the matches inside of a day, which are at or after from, and
+	// before to.
+
+	if tg.regularDay(cd) {
+		return tg.countBefore(to.Sub(cd.start)) - tg.countBefore(from.Sub(cd.start))
+	}
+
+	count := 0
+	tg.eachInDay(cd, from, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			count++
+		}
+		return true
+	})
+	return count
+}
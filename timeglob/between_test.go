@@ -0,0 +1,155 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func validateBetweenMatchesNext(c *check.C, tg *TimeGlob, start, end time.Time) {
+	// Between and Count should agree with repeated calls to Next.
+
+	expected := []time.Time{}
+	for next := tg.Next(start.Add(-time.Nanosecond)); next != UNKNOWN && next.Before(end); next = tg.Next(next) {
+		expected = append(expected, next)
+	}
+
+	c.Check(tg.Between(start, end), check.DeepEquals, expected)
+	c.Check(tg.Count(start, end), check.Equals, len(expected))
+}
+
+func (suite *MySuite) TestBetweenExplicit(c *check.C) {
+	tg, err := Parse("*/*/* 10:15 UTC")
+	c.Assert(err, check.IsNil)
+
+	start := tg.dateNoNormalize(2015, 12, 30, 10, 15, 0)
+	end := tg.dateNoNormalize(2016, 1, 2, 10, 15, 0)
+
+	// Start is included, end is not.
+	c.Check(tg.Between(start, end), check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2015, 12, 30, 10, 15, 0),
+		tg.dateNoNormalize(2015, 12, 31, 10, 15, 0),
+		tg.dateNoNormalize(2016, 1, 1, 10, 15, 0),
+	})
+	c.Check(tg.Count(start, end), check.Equals, 3)
+
+	// Empty and backwards intervals.
+	c.Check(tg.Between(start, start), check.DeepEquals, []time.Time{})
+	c.Check(tg.Count(start, start), check.Equals, 0)
+	c.Check(tg.Between(end, start), check.DeepEquals, []time.Time{})
+	c.Check(tg.Count(end, start), check.Equals, 0)
+}
+
+func (suite *MySuite) TestBetweenTimezone(c *check.C) {
+	tg, err := Parse("12:00 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	// Results are in the timezone of start.
+	c.Check(tg.Between(start, end), check.DeepEquals, []time.Time{
+		time.Date(2016, 1, 1, 17, 0, 0, 0, time.UTC),
+		time.Date(2016, 1, 2, 17, 0, 0, 0, time.UTC),
+	})
+}
+
+func (suite *MySuite) TestBetweenFuncStop(c *check.C) {
+	tg, err := Parse("*:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	start := tg.dateNoNormalize(2016, 1, 1, 0, 0, 0)
+	end := tg.dateNoNormalize(2017, 1, 1, 0, 0, 0)
+
+	results := []time.Time{}
+	tg.BetweenFunc(start, end, func(t time.Time) bool {
+		results = append(results, t)
+		return len(results) < 3
+	})
+
+	c.Check(results, check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2016, 1, 1, 0, 0, 0),
+		tg.dateNoNormalize(2016, 1, 1, 0, 0, 1),
+		tg.dateNoNormalize(2016, 1, 1, 0, 0, 2),
+	})
+}
+
+func (suite *MySuite) TestBetweenDsl(c *check.C) {
+	tg, err := Parse("*:12 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	// The spring day is missing 2 AM, the fall day repeats 1 AM.
+	validateBetweenMatchesNext(c, tg,
+		tg.dateNoNormalize(2016, 3, 12, 0, 0, 0),
+		tg.dateNoNormalize(2016, 3, 15, 0, 0, 0))
+	c.Check(tg.Count(
+		tg.dateNoNormalize(2016, 3, 13, 0, 0, 0),
+		tg.dateNoNormalize(2016, 3, 14, 0, 0, 0)), check.Equals, 23)
+
+	validateBetweenMatchesNext(c, tg,
+		tg.dateNoNormalize(2016, 11, 5, 0, 0, 0),
+		tg.dateNoNormalize(2016, 11, 8, 0, 0, 0))
+	c.Check(tg.Count(
+		tg.dateNoNormalize(2016, 11, 6, 0, 0, 0),
+		tg.dateNoNormalize(2016, 11, 7, 0, 0, 0)), check.Equals, 25)
+}
+
+func (suite *MySuite) TestBetweenCompareNext(c *check.C) {
+	globs := []string{
+		"2015,2016/2,3/10,20 2,14:0,30:15,45 UTC",
+		"*/2/29 America/New_York",
+		"*/*/31 23:59:59 America/New_York",
+		"1,2:0,30 America/New_York",
+		"*/* 25:0 UTC",
+		"*/3/1 1,2:30:0,30.0,500 America/New_York",
+	}
+
+	for _, g := range globs {
+		tg, err := Parse(g)
+		c.Assert(err, check.IsNil)
+
+		validateBetweenMatchesNext(c, tg,
+			tg.dateNoNormalize(2015, 1, 1, 12, 0, 0),
+			tg.dateNoNormalize(2017, 1, 1, 12, 0, 0))
+	}
+}
+
+func (suite *MySuite) TestCountArithmetic(c *check.C) {
+	tg, err := Parse("*:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	// A full month of seconds, without enumerating them.
+	start := tg.dateNoNormalize(2016, 2, 1, 0, 0, 0)
+	end := tg.dateNoNormalize(2016, 3, 1, 0, 0, 0)
+	c.Check(tg.Count(start, end), check.Equals, 29*24*60*60)
+
+	// Partial days on both ends.
+	start = tg.dateNoNormalize(2016, 2, 1, 23, 59, 58)
+	end = tg.dateNoNormalize(2016, 2, 3, 0, 0, 1)
+	c.Check(tg.Count(start, end), check.Equals, 24*60*60+3)
+
+	tg, err = Parse("*/*/1 9,17:0,30 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	start = tg.dateNoNormalize(2015, 1, 1, 0, 0, 0)
+	end = tg.dateNoNormalize(2016, 1, 1, 0, 0, 0)
+	c.Check(tg.Count(start, end), check.Equals, 12*4)
+}
+
+func (suite *MySuite) TestCountMillisecond(c *check.C) {
+	tg, err := Parse("*:*:*.0,250,500,750 UTC")
+	c.Assert(err, check.IsNil)
+
+	start := tg.dateNoNormalize(2016, 2, 1, 0, 0, 0)
+	end := tg.dateNoNormalize(2016, 2, 2, 0, 0, 0)
+	c.Check(tg.Count(start, end), check.Equals, 4*24*60*60)
+
+	start = start.Add(300 * time.Millisecond)
+	end = start.Add(time.Second)
+	c.Check(tg.Between(start, end), check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2016, 2, 1, 0, 0, 0).Add(500 * time.Millisecond),
+		tg.dateNoNormalize(2016, 2, 1, 0, 0, 0).Add(750 * time.Millisecond),
+		tg.dateNoNormalize(2016, 2, 1, 0, 0, 1),
+		tg.dateNoNormalize(2016, 2, 1, 0, 0, 1).Add(250 * time.Millisecond),
+	})
+	c.Check(tg.Count(start, end), check.Equals, 4)
+}
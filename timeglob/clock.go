@@ -0,0 +1,33 @@
+package timeglob
+
+import (
+	"time"
+)
+
+// The source of time used by a Ticker. Tests can substitute a fake clock, such
+// as the one in the timeglobtest package, to control time.
+type Clock interface {
+	Now() time.Time
+
+	// Call f in its own goroutine after d has elapsed.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A timer created by Clock.AfterFunc. *time.Timer implements this.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// The Clock used by default, which uses the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
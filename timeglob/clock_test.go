@@ -0,0 +1,20 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestRealClock(c *check.C) {
+	before := time.Now()
+	now := RealClock.Now()
+	c.Check(now.Before(before), check.Equals, false)
+
+	fired := make(chan bool, 1)
+	timer := RealClock.AfterFunc(time.Millisecond, func() { fired <- true })
+	c.Check(<-fired, check.Equals, true)
+	c.Check(timer.Stop(), check.Equals, false)
+
+	timer = RealClock.AfterFunc(time.Hour, func() { fired <- true })
+	c.Check(timer.Stop(), check.Equals, true)
+}
@@ -0,0 +1,648 @@
+package timeglob
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The flavours of cron expression understood by ParseCron.
+type CronDialect int
+
+const (
+	// Five fields: minute hour day-of-month month day-of-week, as used by
+	// crontab. Day-of-week is 0-7, where 0 and 7 are Sunday.
+	CRON_STANDARD CronDialect = iota
+
+	// Six fields, with seconds first: second minute hour day-of-month month
+	// day-of-week.
+	CRON_SECONDS
+
+	// Quartz: second minute hour day-of-month month day-of-week, and an
+	// optional year. Day-of-week is 1-7, where 1 is Sunday, and one of
+	// day-of-month or day-of-week must be "?".
+	CRON_QUARTZ
+)
+
+// Cron macros, and the standard expressions they stand for.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// A single field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	cronSecond  = cronField{"second", 0, 59, nil}
+	cronMinute  = cronField{"minute", 0, 59, nil}
+	cronHour    = cronField{"hour", 0, 23, nil}
+	cronDay     = cronField{"day-of-month", 1, 31, nil}
+	cronMonth   = cronField{"month", 1, 12, []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	cronWeekday = cronField{"day-of-week", 0, 7, []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+	cronQuartz  = cronField{"day-of-week", 1, 7, []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+	cronYear    = cronField{"year", 1970, 2099, nil}
+)
+
+func ParseCron(expr string, dialect CronDialect) (*TimeGlob, error) {
+	return ParseCronWithOptions(expr, dialect, ParseOptions{})
+}
+
+func ParseCronWithOptions(expr string, dialect CronDialect, options ParseOptions) (*TimeGlob, error) {
+	// Convert a cron expression into a TimeGlob. The expression may start with
+	// "CRON_TZ=<zone>" or "TZ=<zone>" to set the timezone, which is otherwise
+	// Local. Zones are found with options.LocationLoader, or as fixed
+	// offsets, in the same way as globs.
+	//
+	// Day-of-month may use L for the last day, L-n, nW for the nearest
+	// weekday and LW, and day-of-week may use nL for the last weekday n of
+	// the month and n#k for the k-th. Cron matches days which match
+	// day-of-month OR day-of-week if neither starts with "*", and both
+	// otherwise.
+
+	result := new()
+	fields := strings.Fields(expr)
+
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		name := fields[0][strings.Index(fields[0], "=")+1:]
+		loc, err := loadLocation(name, options.LocationLoader)
+		if err != nil {
+			return nil, fmt.Errorf("Not a valid cron expression: %s (%s)", expr, err)
+		}
+		result.location = loc
+		fields = fields[1:]
+	}
+
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		macro, ok := cronMacros[strings.ToLower(fields[0])]
+		if !ok {
+			return nil, fmt.Errorf("Cron expression can't be represented by a TimeGlob: %s (unsupported macro %s)", expr, fields[0])
+		}
+		fields = append([]string{"0"}, strings.Fields(macro)...)
+		dialect = CRON_SECONDS
+	}
+
+	if dialect == CRON_STANDARD {
+		fields = append([]string{"0"}, fields...)
+	}
+
+	return parseCronFields(expr, fields, dialect, cronYear, result.location)
+}
+
+func parseCronFields(expr string, fields []string, dialect CronDialect, yearField cronField, loc *time.Location) (*TimeGlob, error) {
+	// Convert cron fields, starting with seconds, into a TimeGlob in loc. expr
+	// is the original expression, for errors.
+
+	result := new()
+	result.location = loc
+
+	valid := len(fields) == 6 || (dialect == CRON_QUARTZ && len(fields) == 7)
+	if !valid {
+		return nil, fmt.Errorf("Not a valid cron expression: %s", expr)
+	}
+
+	domText, dowText := fields[3], fields[5]
+
+	// Keep the first error.
+	var err error
+	fail := func(e error) {
+		if e != nil && err == nil {
+			err = fmt.Errorf("Not a valid cron expression: %s (%s)", expr, e)
+		}
+	}
+	parse := func(field cronField, text string) []int {
+		values, e := field.parse(text)
+		fail(e)
+		return values
+	}
+
+	second := parse(cronSecond, fields[0])
+	minute := parse(cronMinute, fields[1])
+	hour := parse(cronHour, fields[2])
+	month := parse(cronMonth, fields[4])
+
+	day, nearest, e := parseCronDays(domText)
+	fail(e)
+
+	weekdayField := cronWeekday
+	if dialect == CRON_QUARTZ {
+		weekdayField = cronQuartz
+	}
+	weekday, nth, e := parseCronWeekdays(dowText, weekdayField)
+	fail(e)
+
+	var year []int
+	if len(fields) == 7 {
+		year = parse(yearField, fields[6])
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dialect == CRON_QUARTZ && domText != "?" && dowText != "?" {
+		return nil, fmt.Errorf("Not a valid cron expression: %s (Quartz requires ? for day-of-month or day-of-week)", expr)
+	}
+
+	rules := &dayRules{nth: nth, nearest: nearest}
+
+	domStar := strings.HasPrefix(domText, "*") || domText == "?"
+	dowStar := strings.HasPrefix(dowText, "*") || dowText == "?"
+	if !domStar && !dowStar {
+		// Day-of-month OR day-of-week, so if either matches every day, so
+		// does the expression.
+		if (day == nil && nearest == nil) || (weekday == nil && nth == nil) {
+			day, weekday, rules = nil, nil, &dayRules{}
+		} else {
+			rules.or = true
+		}
+	}
+
+	if rules.nth == nil && rules.nearest == nil && !rules.or {
+		rules = nil
+	}
+
+	result.year = year
+	result.month = month
+	result.day = day
+	result.weekday = weekday
+	result.rules = rules
+	result.hour = hour
+	result.minute = minute
+	result.second = second
+	return &result, nil
+}
+
+func parseCronDays(text string) (days, nearest []int, err error) {
+	// Parse a day-of-month field, which may also use L for the last day, L-n
+	// for n days before it, nW for the weekday nearest day n, and LW for the
+	// last weekday of the month. Days counting back from the end are
+	// negative. Returns nil days if the field matches every day.
+
+	plain := []string{}
+	for _, part := range strings.Split(text, ",") {
+		upper := strings.ToUpper(part)
+
+		switch {
+		case upper == "L":
+			days = append(days, -1)
+
+		case upper == "LW":
+			nearest = append(nearest, -1)
+
+		case strings.HasPrefix(upper, "L-"):
+			n, e := strconv.Atoi(part[2:])
+			if e != nil || n < 0 || n >= cronDay.max {
+				return nil, nil, fmt.Errorf("bad %s: %s", cronDay.name, part)
+			}
+			days = append(days, -(n + 1))
+
+		case strings.HasSuffix(upper, "W"):
+			d, e := cronDay.value(part[:len(part)-1])
+			if e != nil {
+				return nil, nil, e
+			}
+			nearest = append(nearest, d)
+
+		default:
+			plain = append(plain, part)
+		}
+	}
+
+	if len(plain) > 0 {
+		values, e := cronDay.parse(strings.Join(plain, ","))
+		if e != nil {
+			return nil, nil, e
+		}
+		if values == nil {
+			return nil, nil, nil
+		}
+		days = append(days, values...)
+	}
+
+	if days != nil {
+		days = intersect(days, nil)
+	}
+	if nearest != nil {
+		nearest = intersect(nearest, nil)
+	}
+	return days, nearest, nil
+}
+
+func parseCronWeekdays(text string, field cronField) (weekdays []int, nth []nthWeekday, err error) {
+	// Parse a day-of-week field into weekdays from 0 for Sunday to 6, which
+	// may also use nL for the last weekday n of the month, n#k for the k-th
+	// weekday n, and L on its own for Saturday. Returns nil weekdays if the
+	// field matches every day.
+
+	// Convert a value from the field to a weekday.
+	weekday := func(v int) int {
+		return (v - field.min) % 7
+	}
+
+	plain := []string{}
+	for _, part := range strings.Split(text, ",") {
+		upper := strings.ToUpper(part)
+
+		switch {
+		case upper == "L":
+			plain = append(plain, strconv.Itoa(field.min+6))
+
+		case strings.Contains(part, "#"):
+			i := strings.Index(part, "#")
+			v, e := field.value(part[:i])
+			if e != nil {
+				return nil, nil, e
+			}
+			k, e := strconv.Atoi(part[i+1:])
+			if e != nil || k < 1 || k > 5 {
+				return nil, nil, fmt.Errorf("bad %s: %s", field.name, part)
+			}
+			nth = append(nth, nthWeekday{weekday(v), k})
+
+		case len(part) > 1 && strings.HasSuffix(upper, "L"):
+			v, e := field.value(part[:len(part)-1])
+			if e != nil {
+				return nil, nil, e
+			}
+			nth = append(nth, nthWeekday{weekday(v), -1})
+
+		default:
+			plain = append(plain, part)
+		}
+	}
+
+	if len(plain) > 0 {
+		values, e := field.parse(strings.Join(plain, ","))
+		if e != nil {
+			return nil, nil, e
+		}
+
+		days := map[int]bool{}
+		for _, v := range values {
+			days[weekday(v)] = true
+		}
+		weekdays = weekdayList(days)
+		if values == nil || weekdays == nil {
+			return nil, nil, nil
+		}
+	}
+
+	return weekdays, nth, nil
+}
+
+func (f cronField) parse(text string) ([]int, error) {
+	// Parse a cron field with lists, ranges, steps and names into a sorted
+	// list of values. Returns nil if it matches every value.
+
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(text, ",") {
+		rangeText, step := part, 1
+		stepped := false
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangeText = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("bad step in %s: %s", f.name, part)
+			}
+			stepped = true
+		}
+
+		var low, high int
+		var err error
+
+		switch {
+		case rangeText == "*" || rangeText == "?":
+			low, high = f.min, f.max
+
+		case strings.Contains(rangeText, "-"):
+			bounds := strings.SplitN(rangeText, "-", 2)
+			low, err = f.value(bounds[0])
+			if err == nil {
+				high, err = f.value(bounds[1])
+			}
+
+		default:
+			low, err = f.value(rangeText)
+			high = low
+			if stepped {
+				high = f.max
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if low > high {
+			return nil, fmt.Errorf("backwards range in %s: %s", f.name, part)
+		}
+
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+
+	if len(values) == f.max-f.min+1 {
+		return nil, nil
+	}
+
+	result := []int{}
+	for v := range values {
+		result = append(result, v)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	// Parse a single number or name.
+
+	for i, name := range f.names {
+		if strings.EqualFold(text, name) {
+			if f.name == "month" {
+				return i + 1, nil
+			}
+			return i + f.min, nil
+		}
+	}
+
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("bad %s: %s", f.name, text)
+	}
+	return v, nil
+}
+
+// Describes part of a TimeGlob which can't be written as a cron expression.
+type CronProblem struct {
+	// The TimeGlob field, such as "year" or "location".
+	Field string
+
+	// Why it can't be represented.
+	Reason string
+}
+
+// Returned by ToCron when a TimeGlob can't be written in the requested cron
+// dialect.
+type CronError struct {
+	Dialect  CronDialect
+	Problems []CronProblem
+}
+
+func (e *CronError) Error() string {
+	problems := []string{}
+	for _, p := range e.Problems {
+		problems = append(problems, p.Field+": "+p.Reason)
+	}
+	return fmt.Sprintf("TimeGlob can't be represented as cron: %s", strings.Join(problems, "; "))
+}
+
+func (tg *TimeGlob) ToCron(dialect CronDialect) (string, error) {
+	// Write the glob as a cron expression in the given dialect, which
+	// ParseCron would convert back into an equivalent glob. Returns a
+	// *CronError listing every part of the glob which the dialect can't
+	// represent.
+	//
+	// Cron has no timezone, so only globs in Local can be converted. L, W and #
+	// are written for days counting back from the end of the month, nearest
+	// weekdays and single weekdays of the month, though only some cron
+	// implementations understand them. When the clocks go back, cron
+	// implementations run jobs with a wildcard hour in both copies of the
+	// repeated hour, and others once, which matches TimeGlob.
+
+	problems := []CronProblem{}
+	problem := func(field, reason string) {
+		problems = append(problems, CronProblem{field, reason})
+	}
+
+	if tg.location != time.Local {
+		problem("location", "cron expressions are always in the local timezone")
+	}
+
+	if tg.year != nil && dialect != CRON_QUARTZ {
+		problem("year", "only Quartz cron expressions have a year")
+	}
+
+	if !(len(tg.millisecond) == 1 && tg.millisecond[0] == 0) {
+		problem("millisecond", "cron has no fractional seconds")
+	}
+
+	if dialect == CRON_STANDARD && !(len(tg.second) == 1 && tg.second[0] == 0) {
+		problem("second", "standard cron expressions have no seconds")
+	}
+
+	format := func(name string, field cronField, values []int) string {
+		text, ok := field.format(values)
+		if !ok {
+			problem(name, fmt.Sprintf("values must be between %d and %d", field.min, field.max))
+		}
+		return text
+	}
+
+	weekdayField := cronWeekday
+	if dialect == CRON_QUARTZ {
+		weekdayField = cronQuartz
+	}
+
+	rules := tg.rules
+	if rules == nil {
+		rules = &dayRules{}
+	}
+
+	dayText, ok := formatCronDays(tg.day, rules.nearest)
+	if !ok {
+		problem("day", "values must be between 1 and 31, or count back from the end by up to 30")
+	}
+	weekdayText, ok := formatCronWeekdays(tg.weekday, rules.nth, weekdayField)
+	if !ok {
+		problem("weekday", "only the 1st to 5th, or last weekday of the month can be written")
+	}
+
+	// Cron matches both day fields if either starts with "*", and either
+	// otherwise.
+	dayRestricted := dayText != "*"
+	weekdayRestricted := weekdayText != "*"
+	star := strings.HasPrefix(dayText, "*") || strings.HasPrefix(weekdayText, "*")
+	switch {
+	case !dayRestricted || !weekdayRestricted:
+	case dialect == CRON_QUARTZ:
+		problem("weekday", "Quartz can't restrict both the day of the month and the day of the week")
+	case rules.or && star:
+		problem("weekday", "cron only matches either day field if neither starts with *")
+	case !rules.or && !star:
+		problem("weekday", "cron matches either day field if neither starts with *")
+	}
+
+	if dialect == CRON_QUARTZ {
+		if weekdayRestricted {
+			dayText = "?"
+		} else {
+			weekdayText = "?"
+		}
+	}
+
+	fields := []string{
+		format("second", cronSecond, tg.second),
+		format("minute", cronMinute, tg.minute),
+		format("hour", cronHour, tg.hour),
+		dayText,
+		format("month", cronMonth, tg.month),
+		weekdayText,
+	}
+
+	if dialect == CRON_QUARTZ && tg.year != nil {
+		fields = append(fields, format("year", cronYear, tg.year))
+	}
+
+	if dialect == CRON_STANDARD {
+		fields = fields[1:]
+	}
+
+	if len(problems) > 0 {
+		return "", &CronError{dialect, problems}
+	}
+
+	return strings.Join(fields, " "), nil
+}
+
+func formatCronDays(days, nearest []int) (string, bool) {
+	// Write a day-of-month field, using L and W for days which count back
+	// from the end, or the nearest weekday. Returns false if the days can't be
+	// represented.
+
+	if days == nil && nearest == nil {
+		return "*", true
+	}
+
+	positive := []int{}
+	parts := []string{}
+	for _, d := range days {
+		switch {
+		case d > 0:
+			positive = append(positive, d)
+		case d == -1:
+			parts = append(parts, "L")
+		case d < -1 && d > -cronDay.max:
+			parts = append(parts, fmt.Sprintf("L-%d", -d-1))
+		default:
+			return "", false
+		}
+	}
+
+	for _, d := range nearest {
+		switch {
+		case d >= cronDay.min && d <= cronDay.max:
+			parts = append(parts, fmt.Sprintf("%dW", d))
+		case d == -1:
+			parts = append(parts, "LW")
+		default:
+			return "", false
+		}
+	}
+
+	if len(positive) > 0 {
+		text, ok := cronDay.format(positive)
+		if !ok {
+			return "", false
+		}
+		parts = append([]string{text}, parts...)
+	}
+
+	return strings.Join(parts, ","), true
+}
+
+func formatCronWeekdays(weekdays []int, nth []nthWeekday, field cronField) (string, bool) {
+	// Write a day-of-week field in the numbering of field, using # and L for
+	// single occurrences in the month. Returns false if the weekdays can't be
+	// represented.
+
+	if weekdays == nil && nth == nil {
+		return "*", true
+	}
+
+	parts := []string{}
+	if weekdays != nil {
+		values := []int{}
+		for _, d := range weekdays {
+			values = append(values, d+field.min)
+		}
+		text, ok := field.format(values)
+		if !ok {
+			return "", false
+		}
+		parts = append(parts, text)
+	}
+
+	for _, n := range nth {
+		switch {
+		case n.n >= 1 && n.n <= 5:
+			parts = append(parts, fmt.Sprintf("%d#%d", n.weekday+field.min, n.n))
+		case n.n == -1:
+			parts = append(parts, fmt.Sprintf("%dL", n.weekday+field.min))
+		default:
+			return "", false
+		}
+	}
+
+	return strings.Join(parts, ","), true
+}
+
+func (f cronField) format(values []int) (string, bool) {
+	// Write values as a cron field, using a step or ranges where possible.
+	// Returns false if values can't be represented.
+
+	if values == nil {
+		return "*", true
+	}
+
+	for _, v := range values {
+		if v < f.min || v > f.max {
+			return "", false
+		}
+	}
+
+	// Evenly spaced values from the start of the range, like "*/15".
+	if len(values) > 1 && values[0] == f.min {
+		step := values[1] - values[0]
+		stepped := true
+		for i, v := range values {
+			stepped = stepped && v == f.min+i*step
+		}
+		if stepped && values[len(values)-1]+step > f.max {
+			return fmt.Sprintf("*/%d", step), true
+		}
+	}
+
+	parts := []string{}
+	for i := 0; i < len(values); {
+		j := i
+		for j+1 < len(values) && values[j+1] == values[j]+1 {
+			j++
+		}
+
+		if j-i >= 2 {
+			parts = append(parts, fmt.Sprintf("%d-%d", values[i], values[j]))
+		} else {
+			for k := i; k <= j; k++ {
+				parts = append(parts, strconv.Itoa(values[k]))
+			}
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ","), true
+}
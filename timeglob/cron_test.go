@@ -0,0 +1,312 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func cronMatchesGlob(c *check.C, expr string, dialect CronDialect, glob string) {
+	// The cron expression should parse to the same TimeGlob as glob.
+
+	expected, err := Parse(glob)
+	c.Assert(err, check.IsNil)
+
+	tg, err := ParseCron(expr, dialect)
+	c.Check(err, check.IsNil, check.Commentf(expr))
+	c.Check(tg, check.DeepEquals, expected, check.Commentf(expr))
+}
+
+func (suite *MySuite) TestParseCronStandard(c *check.C) {
+	cronMatchesGlob(c, "30 9 * * *", CRON_STANDARD, "9:30")
+	cronMatchesGlob(c, "*/15 * * * *", CRON_STANDARD, "*:0,15,30,45")
+	cronMatchesGlob(c, "0 22-23/1,2 1,15 * *", CRON_STANDARD, "*/1,15 2,22,23:0")
+	cronMatchesGlob(c, "5/20 0 * JAN,jul,12 *", CRON_STANDARD, "1,7,12/* 0:5,25,45")
+	cronMatchesGlob(c, "0 0 ? 2-3 ?", CRON_STANDARD, "2,3/* 0:0")
+	cronMatchesGlob(c, "CRON_TZ=America/New_York 0 9 * * *", CRON_STANDARD, "9:00 America/New_York")
+	cronMatchesGlob(c, "TZ=UTC 0 9 * * *", CRON_STANDARD, "9:00 UTC")
+}
+
+func (suite *MySuite) TestParseCronMacros(c *check.C) {
+	cronMatchesGlob(c, "@yearly", CRON_STANDARD, "1/1 0:00")
+	cronMatchesGlob(c, "@annually", CRON_QUARTZ, "1/1 0:00")
+	cronMatchesGlob(c, "@monthly", CRON_SECONDS, "*/1 0:00")
+	cronMatchesGlob(c, "@daily", CRON_STANDARD, "0:00")
+	cronMatchesGlob(c, "@midnight", CRON_STANDARD, "0:00")
+	cronMatchesGlob(c, "@hourly", CRON_STANDARD, "*:00")
+	cronMatchesGlob(c, "CRON_TZ=UTC @hourly", CRON_STANDARD, "*:00 UTC")
+
+	cronMatchesGlob(c, "@weekly", CRON_STANDARD, "Sun 0:00")
+
+	_, err := ParseCron("@reboot", CRON_STANDARD)
+	c.Check(err, check.ErrorMatches, `.*can't be represented.*@reboot.*`)
+}
+
+func (suite *MySuite) TestParseCronDayOr(c *check.C) {
+	// If neither day field starts with *, cron matches either one. A
+	// day-of-week covering the whole week matches every day.
+	cronMatchesGlob(c, "0 0 1 * 0-6", CRON_STANDARD, "0:00")
+	cronMatchesGlob(c, "0 0 1 * 1-7", CRON_STANDARD, "0:00")
+	cronMatchesGlob(c, "0 0 1 * SUN,MON,TUE,WED,THU,FRI,SAT", CRON_STANDARD, "0:00")
+
+	// If either starts with *, cron matches both.
+	cronMatchesGlob(c, "0 0 1 * */1", CRON_STANDARD, "*/1 0:00")
+	cronMatchesGlob(c, "0 0 */10 * 0-6", CRON_STANDARD, "*/1,11,21,31 0:00")
+	cronMatchesGlob(c, "0 0 * * MON", CRON_STANDARD, "Mon 0:00")
+	cronMatchesGlob(c, "0 0 * * */2", CRON_STANDARD, "Sun,Tue,Thu,Sat 0:00")
+	cronMatchesGlob(c, "0 9 * * 1-5", CRON_STANDARD, "Mon-Fri 9:00")
+	cronMatchesGlob(c, "0 0 */10 * 1", CRON_STANDARD, "Mon */1,11,21,31 0:00")
+
+	// Otherwise, days which match either field.
+	tg, err := ParseCron("CRON_TZ=UTC 0 0 1,15 * 1", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+
+	utc := func(month, day int) time.Time {
+		return time.Date(2016, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+
+	c.Check(tg.NextN(utc(1, 1), 7), check.DeepEquals, []time.Time{
+		utc(1, 4), utc(1, 11), utc(1, 15), utc(1, 18), utc(1, 25), utc(2, 1), utc(2, 8),
+	})
+	c.Check(tg.PrevN(utc(1, 18), 3), check.DeepEquals, []time.Time{utc(1, 18), utc(1, 15), utc(1, 11)})
+	c.Check(tg.Count(utc(1, 1), utc(2, 1)), check.Equals, 6)
+	c.Check(tg.Matches(utc(1, 15)), check.Equals, true)
+	c.Check(tg.Matches(utc(1, 16)), check.Equals, false)
+}
+
+func (suite *MySuite) TestParseCronSpecialDays(c *check.C) {
+	noon := func(month, day int) time.Time {
+		return time.Date(2016, time.Month(month), day, 12, 0, 0, 0, time.UTC)
+	}
+
+	days := []struct {
+		expr     string
+		expected []time.Time
+	}{
+		// The last day, and 3 days before it.
+		{"0 0 12 L * ?", []time.Time{noon(1, 31), noon(2, 29), noon(3, 31)}},
+		{"0 0 12 L-3 * ?", []time.Time{noon(1, 28), noon(2, 26), noon(3, 28)}},
+
+		// The weekday nearest the 15th, which stays in the month.
+		{"0 0 12 15W 1,2,3,5,10 ?", []time.Time{noon(1, 15), noon(2, 15), noon(3, 15), noon(5, 16), noon(10, 14)}},
+		{"0 0 12 1W 10 ?", []time.Time{noon(10, 3)}},
+		{"0 0 12 LW * ?", []time.Time{noon(1, 29), noon(2, 29), noon(3, 31), noon(4, 29)}},
+
+		// The third Friday, the last Friday, and Saturday.
+		{"0 0 12 ? * 6#3", []time.Time{noon(1, 15), noon(2, 19), noon(3, 18)}},
+		{"0 0 12 ? * FRI#3", []time.Time{noon(1, 15), noon(2, 19), noon(3, 18)}},
+		{"0 0 12 ? * 6L", []time.Time{noon(1, 29), noon(2, 26), noon(3, 25)}},
+		{"0 0 12 ? * L", []time.Time{noon(1, 2), noon(1, 9), noon(1, 16)}},
+	}
+
+	for _, d := range days {
+		tg, err := ParseCron("CRON_TZ=UTC "+d.expr, CRON_QUARTZ)
+		c.Assert(err, check.IsNil, check.Commentf(d.expr))
+		c.Check(tg.NextN(noon(1, 1), len(d.expected)), check.DeepEquals, d.expected, check.Commentf(d.expr))
+
+		last := d.expected[len(d.expected)-1]
+		c.Check(tg.Prev(last.Add(time.Hour)), check.Equals, last, check.Commentf(d.expr))
+		c.Check(tg.Matches(last), check.Equals, true, check.Commentf(d.expr))
+		c.Check(tg.Matches(last.AddDate(0, 0, 1)), check.Equals, false, check.Commentf(d.expr))
+	}
+
+	// The standard dialect understands them too.
+	tg, err := ParseCron("CRON_TZ=UTC 0 12 L * 5#3", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	c.Check(tg.NextN(noon(1, 1), 3), check.DeepEquals, []time.Time{noon(1, 15), noon(1, 31), noon(2, 19)})
+}
+
+func (suite *MySuite) TestParseCronSeconds(c *check.C) {
+	cronMatchesGlob(c, "15 30 9 * * *", CRON_SECONDS, "9:30:15")
+	cronMatchesGlob(c, "*/30 * * * * *", CRON_SECONDS, "*:*:0,30")
+
+	_, err := ParseCron("30 9 * * *", CRON_SECONDS)
+	c.Check(err, check.NotNil)
+}
+
+func (suite *MySuite) TestParseCronQuartz(c *check.C) {
+	cronMatchesGlob(c, "0 15 10 ? * *", CRON_QUARTZ, "10:15")
+	cronMatchesGlob(c, "0 15 10 15 * ?", CRON_QUARTZ, "*/15 10:15")
+	cronMatchesGlob(c, "0 0 12 * * ? 2025,2026", CRON_QUARTZ, "2025,2026/*/* 12:00")
+	cronMatchesGlob(c, "0 0 12 ? * 1-7 *", CRON_QUARTZ, "12:00")
+	cronMatchesGlob(c, "0 0/5 14 * * ?", CRON_QUARTZ, "14:0,5,10,15,20,25,30,35,40,45,50,55")
+
+	cronMatchesGlob(c, "0 15 10 ? * MON-FRI", CRON_QUARTZ, "Mon-Fri 10:15")
+	cronMatchesGlob(c, "0 15 10 ? * 2-6", CRON_QUARTZ, "Mon-Fri 10:15")
+
+	bad := map[string]string{
+		"0 15 10 L-31 * ?": "bad day-of-month: L-31",
+		"0 0 12 32W * ?":   "bad day-of-month: 32",
+		"0 15 10 ? * 6#6":  "bad day-of-week: 6#6",
+		"0 15 10 ? * 8L":   "bad day-of-week: 8",
+		"0 15 10 ? * 6#":   "bad day-of-week: 6#",
+		"0 0 12 * * *":     "Quartz requires \\? for day-of-month or day-of-week",
+	}
+	for expr, reason := range bad {
+		_, err := ParseCron(expr, CRON_QUARTZ)
+		c.Check(err, check.ErrorMatches, `.*\(`+reason+`\)`, check.Commentf(expr))
+	}
+
+	// Quartz numbers the week from 1.
+	_, err := ParseCron("0 0 12 ? * 0", CRON_QUARTZ)
+	c.Check(err, check.ErrorMatches, `Not a valid cron expression.*`)
+
+	_, err = ParseCron("0 0 12 ? * * 1969", CRON_QUARTZ)
+	c.Check(err, check.ErrorMatches, `Not a valid cron expression.*`)
+}
+
+func (suite *MySuite) TestParseCronBad(c *check.C) {
+	exprs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 * ",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-2-3 * * * *",
+		"CRON_TZ=Bad/Zone * * * * *",
+	}
+
+	for _, expr := range exprs {
+		_, err := ParseCron(expr, CRON_STANDARD)
+		c.Check(err, check.ErrorMatches, "Not a valid cron expression.*", check.Commentf(expr))
+	}
+}
+
+func (suite *MySuite) TestParseCronNext(c *check.C) {
+	tg, err := ParseCron("CRON_TZ=UTC 0 */6 1 * *", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+
+	now := time.Date(2016, 1, 1, 7, 0, 0, 0, time.UTC)
+	c.Check(tg.NextN(now, 4), check.DeepEquals, []time.Time{
+		time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2016, 1, 1, 18, 0, 0, 0, time.UTC),
+		time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2016, 2, 1, 6, 0, 0, 0, time.UTC),
+	})
+}
+
+func (suite *MySuite) TestToCron(c *check.C) {
+	cases := []struct {
+		glob     string
+		dialect  CronDialect
+		expected string
+	}{
+		{"9:30", CRON_STANDARD, "30 9 * * *"},
+		{"*:0,15,30,45", CRON_STANDARD, "*/15 * * * *"},
+		{"*/1,15 2,22,23:0", CRON_STANDARD, "0 2,22,23 1,15 * *"},
+		{"1,2,3,4,7/* 0:5,25,45", CRON_STANDARD, "5,25,45 0 * 1-4,7 *"},
+		{"*:*:0,30", CRON_SECONDS, "*/30 * * * * *"},
+		{"9:30:15", CRON_SECONDS, "15 30 9 * * *"},
+		{"9,10,11,12:0", CRON_STANDARD, "0 9-12 * * *"},
+		{"*/15 10:15", CRON_QUARTZ, "0 15 10 15 * ?"},
+		{"2025,2026/*/* 12:00", CRON_QUARTZ, "0 0 12 * * ? 2025,2026"},
+		{"Mon-Fri 9:30", CRON_STANDARD, "30 9 * * 1-5"},
+		{"Sun,Tue,Thu,Sat 0:00", CRON_STANDARD, "0 0 * * */2"},
+		{"Mon */1,11,21,31 0:00", CRON_STANDARD, "0 0 */10 * 1"},
+		{"Mon-Fri 9:30", CRON_QUARTZ, "0 30 9 ? * 2-6"},
+		{"Sat,Sun 12:00", CRON_SECONDS, "0 0 12 * * */6"},
+	}
+
+	for _, t := range cases {
+		tg, err := Parse(t.glob)
+		c.Assert(err, check.IsNil)
+
+		expr, err := tg.ToCron(t.dialect)
+		c.Check(err, check.IsNil, check.Commentf(t.glob))
+		c.Check(expr, check.Equals, t.expected, check.Commentf(t.glob))
+
+		// And back again.
+		back, err := ParseCron(expr, t.dialect)
+		c.Check(err, check.IsNil)
+		c.Check(back, check.DeepEquals, tg, check.Commentf(t.glob))
+	}
+}
+
+func (suite *MySuite) TestToCronTimezone(c *check.C) {
+	// Kubernetes CronJobs reject CRON_TZ, so every glob outside of Local is
+	// reported, whether or not its zone has a name.
+	for _, glob := range []string{"9:30 UTC", "9:30 America/New_York", "Mon-Fri 9:30 +05:30", "9:30 PDT"} {
+		tg, err := Parse(glob)
+		c.Assert(err, check.IsNil)
+
+		expr, err := tg.ToCron(CRON_STANDARD)
+		c.Check(expr, check.Equals, "", check.Commentf(glob))
+		c.Check(err, check.DeepEquals, &CronError{CRON_STANDARD, []CronProblem{
+			{"location", "cron expressions are always in the local timezone"},
+		}}, check.Commentf(glob))
+	}
+}
+
+func (suite *MySuite) TestToCronSpecialDays(c *check.C) {
+	// Expressions with L, W, # and days matching either field are written
+	// back out unchanged.
+	cases := []struct {
+		expr    string
+		dialect CronDialect
+	}{
+		{"0 0 1,15 * 1", CRON_STANDARD},
+		{"0 0 L-3,L * *", CRON_STANDARD},
+		{"0 0 LW,15W * *", CRON_STANDARD},
+		{"0 0 1 * 5#3,5L", CRON_STANDARD},
+		{"0 0 12 ? * 6#3", CRON_QUARTZ},
+		{"0 0 12 LW * ?", CRON_QUARTZ},
+	}
+
+	for _, t := range cases {
+		tg, err := ParseCron(t.expr, t.dialect)
+		c.Assert(err, check.IsNil, check.Commentf(t.expr))
+
+		expr, err := tg.ToCron(t.dialect)
+		c.Check(err, check.IsNil, check.Commentf(t.expr))
+		c.Check(expr, check.Equals, t.expr)
+	}
+
+	// Cron can't match both day fields unless one starts with *, and Quartz
+	// can't restrict both.
+	tg, err := Parse("Mon 12/25")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToCron(CRON_STANDARD)
+	c.Check(err.(*CronError).Problems, check.DeepEquals, []CronProblem{
+		{"weekday", "cron matches either day field if neither starts with *"},
+	})
+
+	tg, err = ParseCron("0 0 */2 * 1", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToCron(CRON_QUARTZ)
+	c.Check(err.(*CronError).Problems, check.DeepEquals, []CronProblem{
+		{"weekday", "Quartz can't restrict both the day of the month and the day of the week"},
+	})
+}
+
+func (suite *MySuite) TestToCronErrors(c *check.C) {
+	tg, err := Parse("2016/1/1 9:30:15.5 UTC")
+	c.Assert(err, check.IsNil)
+
+	_, err = tg.ToCron(CRON_STANDARD)
+	c.Check(err, check.DeepEquals, &CronError{CRON_STANDARD, []CronProblem{
+		{"location", "cron expressions are always in the local timezone"},
+		{"year", "only Quartz cron expressions have a year"},
+		{"millisecond", "cron has no fractional seconds"},
+		{"second", "standard cron expressions have no seconds"},
+	}})
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as cron: location: .*; year: .*; millisecond: .*; second: .*")
+
+	// Quartz has seconds and years.
+	_, err = tg.ToCron(CRON_QUARTZ)
+	c.Check(err.(*CronError).Problems, check.DeepEquals, []CronProblem{
+		{"location", "cron expressions are always in the local timezone"},
+		{"millisecond", "cron has no fractional seconds"},
+	})
+
+	tg, err = Parse("*/* 25:0")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToCron(CRON_STANDARD)
+	c.Check(err.(*CronError).Problems, check.DeepEquals, []CronProblem{
+		{"hour", "values must be between 0 and 23"},
+	})
+}
@@ -0,0 +1,177 @@
+package timeglob
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventBridge years cover a longer range than Quartz.
+var eventBridgeYear = cronField{"year", 1970, 2199, nil}
+
+// The units of an EventBridge rate() expression, largest first.
+var eventBridgeUnits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+}
+
+func eventBridgeArgs(expr, name string) (string, bool) {
+	// Return the text inside name(...).
+
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, name+"(") || !strings.HasSuffix(expr, ")") {
+		return "", false
+	}
+	return expr[len(name)+1 : len(expr)-1], true
+}
+
+func ParseEventBridgeCron(expr string) (*TimeGlob, error) {
+	// Convert an AWS EventBridge cron() expression, such as
+	// "cron(0 12 * * ? *)", into a TimeGlob in UTC. The fields are minute,
+	// hour, day-of-month, month, day-of-week and year, and exactly one of
+	// day-of-month or day-of-week must be "?". Day-of-week is 1-7, where 1 is
+	// Sunday. As with ParseCron, L, W and # are supported.
+
+	args, ok := eventBridgeArgs(expr, "cron")
+	fields := strings.Fields(args)
+	if !ok || len(fields) != 6 {
+		return nil, fmt.Errorf("Not a valid EventBridge expression: %s (expected cron() with 6 fields)", expr)
+	}
+
+	if (fields[2] == "?") == (fields[4] == "?") {
+		return nil, fmt.Errorf("Not a valid EventBridge expression: %s (one of day-of-month or day-of-week must be ?)", expr)
+	}
+
+	return parseCronFields(expr, append([]string{"0"}, fields...), CRON_QUARTZ, eventBridgeYear, time.UTC)
+}
+
+func ParseEventBridgeRate(expr string, start time.Time) (*RepeatingInterval, error) {
+	// Convert an AWS EventBridge rate() expression, such as
+	// "rate(5 minutes)", into a RepeatingInterval. EventBridge starts counting
+	// when the rule is created, which is given as start.
+
+	args, ok := eventBridgeArgs(expr, "rate")
+	fields := strings.Fields(args)
+	if !ok || len(fields) != 2 {
+		return nil, fmt.Errorf("Not a valid EventBridge expression: %s (expected rate(value unit))", expr)
+	}
+
+	value, err := strconv.Atoi(fields[0])
+	if err != nil || value < 1 {
+		return nil, fmt.Errorf("Not a valid EventBridge expression: %s (value must be a positive integer)", expr)
+	}
+
+	for _, unit := range eventBridgeUnits {
+		// EventBridge requires "1 minute", but "5 minutes".
+		name := unit.name
+		if value != 1 {
+			name += "s"
+		}
+		if fields[1] == name {
+			return NewRepeatingInterval(start, Period{Duration: time.Duration(value) * unit.duration}, -1)
+		}
+	}
+
+	return nil, fmt.Errorf("Not a valid EventBridge expression: %s (bad unit %s)", expr, fields[1])
+}
+
+func (tg *TimeGlob) ToEventBridge() (string, error) {
+	// Write the glob as an EventBridge cron() expression, which
+	// ParseEventBridgeCron would convert back into an equivalent glob.
+	// EventBridge rules run in UTC, and have no seconds. As in Quartz, either
+	// the day of the month or the day of the week may be restricted, not
+	// both.
+
+	problems := []string{}
+	problem := func(field, reason string) {
+		problems = append(problems, field+": "+reason)
+	}
+
+	if tg.location != time.UTC {
+		problem("location", "EventBridge cron expressions are in UTC")
+	}
+	if !(len(tg.millisecond) == 1 && tg.millisecond[0] == 0) {
+		problem("millisecond", "EventBridge has no fractional seconds")
+	}
+	if !(len(tg.second) == 1 && tg.second[0] == 0) {
+		problem("second", "EventBridge has no seconds")
+	}
+	format := func(name string, field cronField, values []int) string {
+		text, ok := field.format(values)
+		if !ok {
+			problem(name, fmt.Sprintf("values must be between %d and %d", field.min, field.max))
+		}
+		return text
+	}
+
+	rules := tg.rules
+	if rules == nil {
+		rules = &dayRules{}
+	}
+
+	dayText, ok := formatCronDays(tg.day, rules.nearest)
+	if !ok {
+		problem("day", "values must be between 1 and 31, or count back from the end by up to 30")
+	}
+	weekdayText, ok := formatCronWeekdays(tg.weekday, rules.nth, cronQuartz)
+	if !ok {
+		problem("weekday", "only the 1st to 5th, or last weekday of the month can be written")
+	}
+
+	switch {
+	case dayText != "*" && weekdayText != "*":
+		problem("weekday", "EventBridge can't restrict both the day of the month and the day of the week")
+	case weekdayText != "*":
+		dayText = "?"
+	default:
+		weekdayText = "?"
+	}
+
+	fields := []string{
+		format("minute", cronMinute, tg.minute),
+		format("hour", cronHour, tg.hour),
+		dayText,
+		format("month", cronMonth, tg.month),
+		weekdayText,
+		format("year", eventBridgeYear, tg.year),
+	}
+
+	if len(problems) > 0 {
+		return "", fmt.Errorf("TimeGlob can't be represented as EventBridge cron: %s", strings.Join(problems, "; "))
+	}
+
+	return "cron(" + strings.Join(fields, " ") + ")", nil
+}
+
+func (r *RepeatingInterval) ToEventBridge() (string, error) {
+	// Write the schedule as an EventBridge rate() expression. The start is
+	// lost, since EventBridge counts from when the rule is created, so only
+	// schedules which repeat forever, by a whole number of minutes, can be
+	// written.
+
+	p := r.period
+	if r.count >= 0 || p.Years != 0 || p.Months != 0 || p.Days != 0 || p.Duration%time.Minute != 0 {
+		return "", fmt.Errorf("RepeatingInterval can't be represented as EventBridge rate: %s", r)
+	}
+
+	// The largest unit which divides the duration, which is at least minutes.
+	unit := eventBridgeUnits[len(eventBridgeUnits)-1]
+	for _, u := range eventBridgeUnits {
+		if p.Duration%u.duration == 0 {
+			unit = u
+			break
+		}
+	}
+
+	value := int(p.Duration / unit.duration)
+	name := unit.name
+	if value != 1 {
+		name += "s"
+	}
+	return fmt.Sprintf("rate(%d %s)", value, name), nil
+}
@@ -0,0 +1,204 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func eventBridgeMatchesGlob(c *check.C, expr string, glob string) {
+	// The EventBridge expression should parse to the same TimeGlob as glob.
+
+	expected, err := Parse(glob)
+	c.Assert(err, check.IsNil)
+
+	tg, err := ParseEventBridgeCron(expr)
+	c.Check(err, check.IsNil, check.Commentf(expr))
+	c.Check(tg, check.DeepEquals, expected, check.Commentf(expr))
+}
+
+func (suite *MySuite) TestParseEventBridgeCron(c *check.C) {
+	// Examples from the EventBridge documentation.
+	eventBridgeMatchesGlob(c, "cron(15 10 * * ? *)", "10:15 UTC")
+	eventBridgeMatchesGlob(c, "cron(0 12 * * ? *)", "12:00 UTC")
+	eventBridgeMatchesGlob(c, "cron(0/15 * * * ? *)", "*:0,15,30,45 UTC")
+	eventBridgeMatchesGlob(c, "cron(0 8 1 * ? *)", "*/1 8:00 UTC")
+	eventBridgeMatchesGlob(c, "cron(0 9 ? * SUN-SAT *)", "9:00 UTC")
+	eventBridgeMatchesGlob(c, "cron(0 9 ? * 1-7 *)", "9:00 UTC")
+
+	// The year field, which goes past Quartz's 2099.
+	eventBridgeMatchesGlob(c, "cron(0 0 1 1 ? 2025)", "2025/1/1 0:00 UTC")
+	eventBridgeMatchesGlob(c, "cron(0 0 1 1 ? 2025-2027,2150)", "2025,2026,2027,2150/1/1 0:00 UTC")
+	eventBridgeMatchesGlob(c, "cron(0 0 1 JAN,JUL ? *)", "1,7/1 0:00 UTC")
+}
+
+func (suite *MySuite) TestParseEventBridgeCronDays(c *check.C) {
+	// Day-of-week is 1-7 from Sunday, or names, with L, W and # as in
+	// Quartz. 2025/1/1 is a Wednesday.
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	utc := func(month, day, hour, minute int) time.Time {
+		return time.Date(2025, time.Month(month), day, hour, minute, 0, 0, time.UTC)
+	}
+
+	days := []struct {
+		expr     string
+		expected []time.Time
+	}{
+		// Weekdays at noon.
+		{"cron(0 12 ? * MON-FRI *)", []time.Time{utc(1, 1, 12, 0), utc(1, 2, 12, 0), utc(1, 3, 12, 0), utc(1, 6, 12, 0)}},
+		{"cron(0 12 ? * 2-6 *)", []time.Time{utc(1, 1, 12, 0), utc(1, 2, 12, 0), utc(1, 3, 12, 0), utc(1, 6, 12, 0)}},
+		// Weekends, with 1 for Sunday.
+		{"cron(0 18 ? * 1,7 *)", []time.Time{utc(1, 4, 18, 0), utc(1, 5, 18, 0), utc(1, 11, 18, 0), utc(1, 12, 18, 0)}},
+		{"cron(0 18 ? * SAT,SUN *)", []time.Time{utc(1, 4, 18, 0), utc(1, 5, 18, 0), utc(1, 11, 18, 0), utc(1, 12, 18, 0)}},
+		// The last day of the month.
+		{"cron(0 10 L * ? *)", []time.Time{utc(1, 31, 10, 0), utc(2, 28, 10, 0), utc(3, 31, 10, 0)}},
+		// The weekday nearest the 15th.
+		{"cron(0 10 15W * ? *)", []time.Time{utc(1, 15, 10, 0), utc(2, 14, 10, 0), utc(3, 14, 10, 0), utc(4, 15, 10, 0)}},
+		// The last Friday of the month.
+		{"cron(0 10 ? * 6L *)", []time.Time{utc(1, 31, 10, 0), utc(2, 28, 10, 0), utc(3, 28, 10, 0)}},
+		// The third Friday of the month.
+		{"cron(0 10 ? * 6#3 *)", []time.Time{utc(1, 17, 10, 0), utc(2, 21, 10, 0), utc(3, 21, 10, 0)}},
+		// Saturday, on its own.
+		{"cron(0 10 ? * L *)", []time.Time{utc(1, 4, 10, 0), utc(1, 11, 10, 0)}},
+	}
+
+	for _, d := range days {
+		tg, err := ParseEventBridgeCron(d.expr)
+		c.Assert(err, check.IsNil, check.Commentf(d.expr))
+		c.Check(tg.NextN(start, len(d.expected)), check.DeepEquals, d.expected, check.Commentf(d.expr))
+	}
+}
+
+func (suite *MySuite) TestParseEventBridgeCronBad(c *check.C) {
+	bad := map[string]string{
+		"0 12 * * ? *":             "Not a valid EventBridge expression.*",
+		"cron(0 12 * * ?)":         "Not a valid EventBridge expression.*",
+		"cron(0 0 12 * * ? *)":     "Not a valid EventBridge expression.*",
+		"rate(5 minutes)":          "Not a valid EventBridge expression.*",
+		"cron(0 12 * * * *)":       "Not a valid EventBridge expression.*\\(one of day-of-month or day-of-week must be \\?\\)",
+		"cron(0 12 ? * ? *)":       "Not a valid EventBridge expression.*\\(one of day-of-month or day-of-week must be \\?\\)",
+		"cron(0 12 1 * 2 *)":       "Not a valid EventBridge expression.*\\(one of day-of-month or day-of-week must be \\?\\)",
+		"cron(0 0 1 1 ? 1969)":     "Not a valid cron expression.*",
+		"cron(0 0 1 1 ? 2200)":     "Not a valid cron expression.*",
+		"cron(60 0 * * ? *)":       "Not a valid cron expression.*",
+		"cron(0 18 ? * MON-SUN *)": "Not a valid cron expression.*",
+		"cron(0 10 L-40 * ? *)":    "Not a valid cron expression.*",
+		"cron(0 10 ? * 6#0 *)":     "Not a valid cron expression.*",
+	}
+
+	for expr, message := range bad {
+		_, err := ParseEventBridgeCron(expr)
+		c.Check(err, check.ErrorMatches, message, check.Commentf(expr))
+	}
+}
+
+func (suite *MySuite) TestParseEventBridgeRate(c *check.C) {
+	start := time.Date(2025, 1, 1, 10, 7, 0, 0, time.UTC)
+
+	rates := map[string]time.Duration{
+		"rate(1 minute)":    time.Minute,
+		"rate(5 minutes)":   5 * time.Minute,
+		"rate(1 hour)":      time.Hour,
+		"rate(12 hours)":    12 * time.Hour,
+		"rate(1 day)":       24 * time.Hour,
+		"rate(7 days)":      7 * 24 * time.Hour,
+		" rate(90 minutes)": 90 * time.Minute,
+	}
+
+	for expr, d := range rates {
+		r, err := ParseEventBridgeRate(expr, start)
+		c.Assert(err, check.IsNil, check.Commentf(expr))
+		c.Check(r.Start(), check.Equals, start)
+		c.Check(r.Period(), check.Equals, Period{Duration: d})
+		c.Check(r.Count(), check.Equals, -1)
+		c.Check(r.Next(start), check.Equals, start.Add(d))
+	}
+
+	for _, expr := range []string{"rate(5 minute)", "rate(1 minutes)", "rate(0 minutes)", "rate(-5 minutes)",
+		"rate(5 seconds)", "rate(5)", "rate(five minutes)", "cron(0 12 * * ? *)", "rate 5 minutes"} {
+		_, err := ParseEventBridgeRate(expr, start)
+		c.Check(err, check.ErrorMatches, "Not a valid EventBridge expression.*", check.Commentf(expr))
+	}
+}
+
+func (suite *MySuite) TestToEventBridge(c *check.C) {
+	cases := []struct {
+		glob     string
+		expected string
+	}{
+		{"10:15 UTC", "cron(15 10 * * ? *)"},
+		{"*:0,15,30,45 UTC", "cron(*/15 * * * ? *)"},
+		{"*/1 8:00 UTC", "cron(0 8 1 * ? *)"},
+		{"2025,2026,2027,2150/1/1 0:00 UTC", "cron(0 0 1 1 ? 2025-2027,2150)"},
+		{"Mon-Fri 12:00 UTC", "cron(0 12 ? * 2-6 *)"},
+		{"Sat,Sun 18:00 UTC", "cron(0 18 ? * */6 *)"},
+	}
+
+	for _, t := range cases {
+		tg, err := Parse(t.glob)
+		c.Assert(err, check.IsNil)
+
+		expr, err := tg.ToEventBridge()
+		c.Check(err, check.IsNil, check.Commentf(t.glob))
+		c.Check(expr, check.Equals, t.expected, check.Commentf(t.glob))
+
+		// And back again.
+		back, err := ParseEventBridgeCron(expr)
+		c.Check(err, check.IsNil, check.Commentf(expr))
+		c.Check(back, check.DeepEquals, tg, check.Commentf(t.glob))
+	}
+
+	// Days counting back from the end of the month, nearest weekdays and
+	// numbered weekdays go back and forth too.
+	for _, expr := range []string{"cron(0 10 L * ? *)", "cron(0 10 15W * ? *)", "cron(0 10 ? * 6L *)", "cron(0 10 ? * 6#3 *)"} {
+		tg, err := ParseEventBridgeCron(expr)
+		c.Assert(err, check.IsNil, check.Commentf(expr))
+
+		text, err := tg.ToEventBridge()
+		c.Check(err, check.IsNil, check.Commentf(expr))
+		c.Check(text, check.Equals, expr)
+	}
+
+	tg, err := Parse("Mon */1 9:00 UTC")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToEventBridge()
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as EventBridge cron: "+
+		"weekday: EventBridge can't restrict both the day of the month and the day of the week")
+
+	tg, err = Parse("9:30:15.5")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToEventBridge()
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as EventBridge cron: "+
+		"location: EventBridge cron expressions are in UTC; millisecond: .*; second: EventBridge has no seconds")
+}
+
+func (suite *MySuite) TestRepeatingIntervalToEventBridge(c *check.C) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rates := map[time.Duration]string{
+		time.Minute:        "rate(1 minute)",
+		90 * time.Minute:   "rate(90 minutes)",
+		2 * time.Hour:      "rate(2 hours)",
+		24 * time.Hour:     "rate(1 day)",
+		7 * 24 * time.Hour: "rate(7 days)",
+	}
+
+	for d, expected := range rates {
+		r, err := NewRepeatingInterval(start, Period{Duration: d}, -1)
+		c.Assert(err, check.IsNil)
+
+		expr, err := r.ToEventBridge()
+		c.Check(err, check.IsNil)
+		c.Check(expr, check.Equals, expected)
+
+		back, err := ParseEventBridgeRate(expr, start)
+		c.Check(err, check.IsNil)
+		c.Check(back, check.DeepEquals, r)
+	}
+
+	for _, text := range []string{"R5/2025-01-01T00:00:00Z/PT5M", "R/2025-01-01T00:00:00Z/P1D", "R/2025-01-01T00:00:00Z/PT30S"} {
+		r, err := ParseRepeatingInterval(text)
+		c.Assert(err, check.IsNil)
+		_, err = r.ToEventBridge()
+		c.Check(err, check.ErrorMatches, "RepeatingInterval can't be represented as EventBridge rate: .*", check.Commentf(text))
+	}
+}
@@ -0,0 +1,315 @@
+package timeglob
+
+import (
+	"time"
+)
+
+// Except searches this many years after, or before now for a match which
+// isn't excluded, before deciding the exclusion covers everything.
+const EXCEPT_YEAR_SEARCH_DEPTH = WEEKDAY_YEAR_SEARCH_DEPTH
+
+// Except checks at most this many excluded matches one at a time, when it
+// can't skip the days they're on, before giving up.
+const EXCEPT_CHECK_LIMIT = 1000000
+
+// Except searches windows of matches, starting with this long, and doubling
+// up to exceptMaxWindow.
+const (
+	exceptMinWindow = time.Second
+	exceptMaxWindow = 366 * 24 * time.Hour
+)
+
+// A Schedule which matches whenever another Schedule matches, except at times
+// matched by an exclusion, such as a freeze window, or a list of holidays.
+type Except struct {
+	schedule Schedule
+	exclude  Schedule
+}
+
+func NewExcept(schedule, exclude Schedule) *Except {
+	// Create a Schedule which matches each match of schedule, unless exclude
+	// also matches it.
+	return &Except{schedule, exclude}
+}
+
+func (e *Except) Schedule() Schedule {
+	return e.schedule
+}
+
+func (e *Except) Exclude() Schedule {
+	return e.exclude
+}
+
+func (e *Except) Next(now time.Time) time.Time {
+	// Find the first match after now which isn't excluded. Returns UNKNOWN if
+	// there isn't one within EXCEPT_YEAR_SEARCH_DEPTH years, or more than
+	// EXCEPT_CHECK_LIMIT excluded matches had to be checked one at a time.
+	// The result is in the same timezone as now.
+
+	limit := now.AddDate(EXCEPT_YEAR_SEARCH_DEPTH, 0, 0)
+	checks := EXCEPT_CHECK_LIMIT
+	result := UNKNOWN
+	from := now
+
+	for window := exceptMinWindow; result == UNKNOWN; window = growWindow(window) {
+		// Skip straight to the next match, so sparse schedules don't need
+		// many windows.
+		first := e.schedule.Next(from)
+		if first == UNKNOWN || first.After(limit) {
+			return UNKNOWN
+		}
+
+		to := first.Add(window)
+		if !e.scan(first, to, &checks, func(t time.Time) bool {
+			result = t
+			return false
+		}) {
+			return UNKNOWN
+		}
+		from = to.Add(-time.Nanosecond)
+	}
+
+	return result.In(now.Location())
+}
+
+func (e *Except) Prev(now time.Time) time.Time {
+	// Find the last match before, or equal to now which isn't excluded.
+	// Returns UNKNOWN if there isn't one within EXCEPT_YEAR_SEARCH_DEPTH
+	// years, or more than EXCEPT_CHECK_LIMIT excluded matches had to be
+	// checked one at a time. The result is in the same timezone as now.
+
+	limit := now.AddDate(-EXCEPT_YEAR_SEARCH_DEPTH, 0, 0)
+	checks := EXCEPT_CHECK_LIMIT
+	result := UNKNOWN
+	to := now
+
+	for window := exceptMinWindow; result == UNKNOWN; window = growWindow(window) {
+		last := e.schedule.Prev(to)
+		if last == UNKNOWN || last.Before(limit) {
+			return UNKNOWN
+		}
+
+		// Search forwards through the window, keeping the last match which
+		// isn't excluded.
+		from := last.Add(-window)
+		if !e.scan(from, last.Add(time.Nanosecond), &checks, func(t time.Time) bool {
+			result = t
+			return true
+		}) {
+			return UNKNOWN
+		}
+		to = from.Add(-time.Nanosecond)
+	}
+
+	return result.In(now.Location())
+}
+
+func (e *Except) scan(start, end time.Time, checks *int, fn func(time.Time) bool) bool {
+	// Call fn with each match which is at, or after start, and before end,
+	// and isn't excluded, in order. Stops early if fn returns false. Days on
+	// which the exclusion matches every match are skipped at once, so long
+	// exclusions of dense schedules, like a week of every second, are quick.
+	// Other excluded matches use up checks, if it isn't nil. Returns false if
+	// they ran out.
+
+	for start.Before(end) {
+		skip := UNKNOWN
+		stopped, exhausted := false, false
+
+		ScheduleBetweenFunc(e.schedule, start, end, func(t time.Time) bool {
+			if !ScheduleMatches(e.exclude, t) {
+				stopped = !fn(t)
+				return !stopped
+			}
+			if _, covered := coveredDays(e.exclude, e.schedule, t, t, end); covered.After(t) {
+				skip = covered
+				return false
+			}
+			if checks != nil {
+				*checks--
+				exhausted = *checks <= 0
+			}
+			return !exhausted
+		})
+
+		if exhausted {
+			return false
+		}
+		if stopped || skip == UNKNOWN {
+			return true
+		}
+		start = skip
+	}
+	return true
+}
+
+func coveredDays(exclude, schedule Schedule, t, from, to time.Time) (time.Time, time.Time) {
+	// The span of whole days around t, clipped to from and to, in which
+	// exclude matches every match of schedule. Returns t, t if there isn't
+	// one, t isn't between from and to, or exclude can't tell.
+
+	if t.Before(from) || !t.Before(to) {
+		return t, t
+	}
+
+	switch x := exclude.(type) {
+	case *TimeGlob:
+		return x.coveredDays(schedule, t, from, to)
+
+	case *Union:
+		// Members may cover neighbouring days, so join their spans.
+		start, end := t, t
+		for changed := true; changed; {
+			changed = false
+			for _, member := range x.schedules {
+				if s, e := coveredDays(member, schedule, start.Add(-time.Nanosecond), from, to); s.Before(e) {
+					start, changed = s, true
+				}
+				if _, e := coveredDays(member, schedule, end, from, to); e.After(end) {
+					end, changed = e, true
+				}
+			}
+		}
+		return start, end
+	}
+
+	return t, t
+}
+
+func (tg *TimeGlob) coveredDays(schedule Schedule, t, from, to time.Time) (time.Time, time.Time) {
+	// The span of whole days around t, clipped to from and to, in which the
+	// glob matches every match of schedule. That's every day whose date the
+	// glob matches, if schedule is made of globs whose times of day the
+	// glob's time fields include, once converted to the glob's timezone.
+	// Returns t, t otherwise.
+
+	midnight := func(date time.Time) time.Time {
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, tg.location)
+	}
+
+	// Dates are stepped at noon in UTC, so they're never skipped or repeated.
+	coversDate := func(date time.Time) bool {
+		return inValues(tg.year, date.Year()) && inValues(tg.month, int(date.Month())) &&
+			tg.matchesDay(date.Year(), int(date.Month()), date.Day()) &&
+			tg.coversTimes(schedule, midnight(date), midnight(date.AddDate(0, 0, 1)))
+	}
+
+	local := t.In(tg.location)
+	date := time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, time.UTC)
+	if !coversDate(date) {
+		return t, t
+	}
+
+	first, last := date, date
+	for midnight(first).After(from) && coversDate(first.AddDate(0, 0, -1)) {
+		first = first.AddDate(0, 0, -1)
+	}
+	for midnight(last.AddDate(0, 0, 1)).Before(to) && coversDate(last.AddDate(0, 0, 1)) {
+		last = last.AddDate(0, 0, 1)
+	}
+
+	start, end := midnight(first), midnight(last.AddDate(0, 0, 1))
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	return start, end
+}
+
+func (tg *TimeGlob) coversTimes(schedule Schedule, from, to time.Time) bool {
+	// Do the glob's time fields include every time of day which schedule can
+	// match between from and to, once converted to the glob's timezone?
+
+	switch s := schedule.(type) {
+	case *TimeGlob:
+		if !includesValues(tg.second, s.second) || !includesValues(tg.millisecond, s.millisecond) {
+			return false
+		}
+
+		// Timezones whose UTC offsets differ by whole minutes agree on the
+		// seconds, and by whole hours on the minutes too.
+		switch {
+		case tg.hour == nil && tg.minute == nil:
+			return offsetsDifferBy(tg.location, s.location, from, to, time.Minute)
+		case tg.hour == nil && includesValues(tg.minute, s.minute):
+			return offsetsDifferBy(tg.location, s.location, from, to, time.Hour)
+		case includesValues(tg.hour, s.hour) && includesValues(tg.minute, s.minute):
+			return offsetsDifferBy(tg.location, s.location, from, to, 0)
+		}
+		return false
+
+	case *Union:
+		for _, member := range s.schedules {
+			if !tg.coversTimes(member, from, to) {
+				return false
+			}
+		}
+		return len(s.schedules) > 0
+	}
+
+	return false
+}
+
+func offsetsDifferBy(a, b *time.Location, from, to time.Time, unit time.Duration) bool {
+	// Do the UTC offsets of a and b differ by a multiple of unit, or not at
+	// all if unit is 0, everywhere between from and to? Offsets are compared
+	// every 15 minutes, which is as often as timezones change them.
+
+	if a.String() == b.String() {
+		return true
+	}
+
+	for t := from; t.Before(to); t = t.Add(15 * time.Minute) {
+		_, offsetA := t.In(a).Zone()
+		_, offsetB := t.In(b).Zone()
+		diff := time.Duration(offsetA-offsetB) * time.Second
+		if (unit == 0 && diff != 0) || (unit != 0 && diff%unit != 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func includesValues(values, others []int) bool {
+	// Is every value in others also in values? nil is every value.
+
+	if values == nil {
+		return true
+	}
+	if others == nil {
+		return false
+	}
+	for _, v := range others {
+		if !inValues(values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func growWindow(window time.Duration) time.Duration {
+	if window < exceptMaxWindow {
+		window *= 2
+	}
+	return window
+}
+
+func (e *Except) Matches(t time.Time) bool {
+	return ScheduleMatches(e.schedule, t) && !ScheduleMatches(e.exclude, t)
+}
+
+func (e *Except) BetweenFunc(start, end time.Time, fn func(time.Time) bool) {
+	// Call fn with each match which is at, or after start, and before end, in
+	// order. Unlike Next, there's no limit on how many excluded matches are
+	// skipped.
+
+	e.scan(start, end, nil, fn)
+}
+
+func (e *Except) Location() *time.Location {
+	// The timezone of the schedule, if it has one, so Tickers report times in
+	// it.
+	return scheduleLocation(e.schedule)
+}
@@ -0,0 +1,188 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestExcept(c *check.C) {
+	s, err := ParseSchedule("*:0 UTC except 12/24,25,26 *:* UTC")
+	c.Assert(err, check.IsNil)
+	_, ok := s.(*Except)
+	c.Assert(ok, check.Equals, true)
+
+	utc := func(month, day, hour int) time.Time {
+		return time.Date(2016, time.Month(month), day, hour, 0, 0, 0, time.UTC)
+	}
+
+	c.Check(s.Next(utc(12, 1, 0)), check.Equals, utc(12, 1, 1))
+	c.Check(s.Next(utc(12, 23, 23)), check.Equals, utc(12, 27, 0))
+	c.Check(s.Prev(utc(12, 27, 0).Add(-time.Nanosecond)), check.Equals, utc(12, 23, 23))
+	c.Check(s.Prev(utc(12, 27, 0)), check.Equals, utc(12, 27, 0))
+
+	c.Check(ScheduleMatches(s, utc(12, 23, 5)), check.Equals, true)
+	c.Check(ScheduleMatches(s, utc(12, 25, 5)), check.Equals, false)
+	c.Check(ScheduleCount(s, utc(12, 20, 0), utc(12, 30, 0)), check.Equals, 7*24)
+
+	// Results are in the timezone of now.
+	ny, err := time.LoadLocation("America/New_York")
+	c.Assert(err, check.IsNil)
+	c.Check(s.Next(utc(12, 23, 23).In(ny)).Location(), check.Equals, ny)
+}
+
+func (suite *MySuite) TestExceptParse(c *check.C) {
+	holidays, err := ParseSchedule("1/1 *:* UTC | 12/25 *:* UTC")
+	c.Assert(err, check.IsNil)
+
+	options := ParseOptions{Schedules: map[string]Schedule{"Holidays": holidays}}
+
+	// Everything after except is excluded.
+	s, err := ParseScheduleWithOptions("9:00 UTC | 17:00 UTC except Holidays except 12/24 *:* UTC", options)
+	c.Assert(err, check.IsNil)
+
+	e, ok := s.(*Except)
+	c.Assert(ok, check.Equals, true)
+	c.Check(e.Schedule(), check.FitsTypeOf, &Union{})
+	c.Check(e.Exclude(), check.FitsTypeOf, &Union{})
+
+	expected := []time.Time{
+		time.Date(2016, 12, 23, 17, 0, 0, 0, time.UTC),
+		time.Date(2016, 12, 26, 9, 0, 0, 0, time.UTC),
+		time.Date(2016, 12, 26, 17, 0, 0, 0, time.UTC),
+	}
+
+	now := time.Date(2016, 12, 23, 12, 0, 0, 0, time.UTC)
+	for _, e := range expected {
+		now = s.Next(now)
+		c.Check(now, check.Equals, e)
+	}
+
+	for _, text := range []string{"*:0 UTC except", "except *:0 UTC", "*:0 UTC except  except 9:00"} {
+		_, err := ParseScheduleWithOptions(text, options)
+		c.Check(err, check.ErrorMatches, "Not a valid schedule: .* \\(empty glob\\)", check.Commentf(text))
+	}
+
+	_, err = ParseSchedule("*:0 UTC except Holidays")
+	c.Check(err, check.ErrorMatches, "Not a valid TimeGlob: Holidays")
+}
+
+func (suite *MySuite) TestExceptEverything(c *check.C) {
+	// An exclusion which covers every match gives up, instead of searching
+	// forever.
+	s, err := ParseSchedule("*:* UTC except */* *:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Check(s.Next(now), check.Equals, UNKNOWN)
+	c.Check(s.Prev(now), check.Equals, UNKNOWN)
+	c.Check(ScheduleCount(s, now, now.AddDate(0, 0, 1)), check.Equals, 0)
+}
+
+func (suite *MySuite) TestExceptLongFreeze(c *check.C) {
+	// A week of excluded matches every minute is still found.
+	s, err := ParseSchedule("*:*:0 UTC except 2016/12/24-30 *:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	c.Check(s.Next(time.Date(2016, 12, 23, 23, 59, 0, 0, time.UTC)), check.Equals, time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC))
+	c.Check(s.Prev(time.Date(2016, 12, 30, 23, 59, 0, 0, time.UTC)), check.Equals, time.Date(2016, 12, 23, 23, 59, 0, 0, time.UTC))
+}
+
+func (suite *MySuite) TestExceptEverySecond(c *check.C) {
+	// Excluded days are skipped at once, rather than a second at a time.
+	s, err := ParseSchedule("*:*:* UTC except 2016/12/24,25 *:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	c.Check(s.Next(time.Date(2016, 12, 23, 23, 59, 59, 0, time.UTC)), check.Equals, time.Date(2016, 12, 26, 0, 0, 0, 0, time.UTC))
+	c.Check(s.Prev(time.Date(2016, 12, 25, 23, 59, 59, 0, time.UTC)), check.Equals, time.Date(2016, 12, 23, 23, 59, 59, 0, time.UTC))
+}
+
+func (suite *MySuite) TestExceptRare(c *check.C) {
+	// The next Monday, February 29th after 2044 is in 2072.
+	s, err := ParseSchedule("Mon 2/29 0:00 UTC except 2044/2/29 *:* UTC")
+	c.Assert(err, check.IsNil)
+
+	c.Check(s.Next(time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)), check.Equals, time.Date(2072, 2, 29, 0, 0, 0, 0, time.UTC))
+}
+
+func (suite *MySuite) TestExceptOtherTimezone(c *check.C) {
+	// Exclusions in another timezone skip whole days in their own timezone.
+	s, err := ParseSchedule("*:0 UTC except 12/24-26 *:* America/New_York")
+	c.Assert(err, check.IsNil)
+
+	now := time.Date(2016, 12, 24, 4, 0, 0, 0, time.UTC)
+	c.Check(s.Next(now), check.Equals, time.Date(2016, 12, 27, 5, 0, 0, 0, time.UTC))
+	c.Check(s.Prev(time.Date(2016, 12, 27, 5, 0, 0, 0, time.UTC).Add(-time.Nanosecond)), check.Equals, now)
+
+	// Across a change to summer time, with a match every second.
+	s, err = ParseSchedule("*:*:* UTC except 2016/3/12-14 *:*:* America/New_York")
+	c.Assert(err, check.IsNil)
+
+	now = time.Date(2016, 3, 12, 4, 59, 59, 0, time.UTC)
+	c.Check(s.Next(now), check.Equals, time.Date(2016, 3, 15, 4, 0, 0, 0, time.UTC))
+	c.Check(s.Prev(time.Date(2016, 3, 15, 3, 59, 59, 0, time.UTC)), check.Equals, now)
+
+	// An offset of half an hour still agrees on the seconds, but not on the
+	// minutes, so each excluded match is checked.
+	s, err = ParseSchedule("*:0 UTC except 2016/12/25 *:0 Asia/Kolkata")
+	c.Assert(err, check.IsNil)
+	c.Check(s.Next(time.Date(2016, 12, 24, 18, 0, 0, 0, time.UTC)), check.Equals, time.Date(2016, 12, 24, 19, 0, 0, 0, time.UTC))
+
+	// Covering everything in another timezone gives up quickly.
+	s, err = ParseSchedule("*:*:* UTC except *:*:* Europe/London")
+	c.Assert(err, check.IsNil)
+
+	now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Check(s.Next(now), check.Equals, UNKNOWN)
+	c.Check(s.Prev(now), check.Equals, UNKNOWN)
+}
+
+func (suite *MySuite) TestExceptCheckLimit(c *check.C) {
+	// Exclusions which can't skip days, like a RepeatingInterval, give up
+	// after EXCEPT_CHECK_LIMIT excluded matches.
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	every, err := NewRepeatingInterval(start, Period{Duration: time.Second}, -1)
+	c.Assert(err, check.IsNil)
+
+	s, err := Parse("*:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	e := NewExcept(s, every)
+	c.Check(e.Next(start), check.Equals, UNKNOWN)
+	c.Check(e.Prev(start.AddDate(1, 0, 0)), check.Equals, UNKNOWN)
+
+	// Matches before the limit runs out are still found.
+	c.Check(e.Prev(start.Add(time.Hour)), check.Equals, start.Add(-time.Second))
+}
+
+func (suite *MySuite) TestExceptReadme(c *check.C) {
+	// The examples from the README, as written.
+	s, err := ParseSchedule("*:0 except 12/24-26 *:*")
+	c.Assert(err, check.IsNil)
+
+	local := func(month, day, hour int) time.Time {
+		return time.Date(2016, time.Month(month), day, hour, 0, 0, 0, time.Local)
+	}
+	c.Check(s.Next(local(12, 23, 23)), check.Equals, local(12, 27, 0))
+	c.Check(s.Prev(local(12, 27, 0).Add(-time.Nanosecond)), check.Equals, local(12, 23, 23))
+	c.Check(ScheduleCount(s, local(12, 20, 0), local(12, 30, 0)), check.Equals, 7*24)
+
+	holidays, err := ParseSchedule("12/25,26 *:* | 1/1 *:*")
+	c.Assert(err, check.IsNil)
+	options := ParseOptions{Schedules: map[string]Schedule{"Holidays": holidays}}
+
+	s, err = ParseScheduleWithOptions("Mon-Fri 9:00 except Holidays", options)
+	c.Assert(err, check.IsNil)
+
+	expected := []time.Time{
+		local(12, 27, 9),
+		local(12, 28, 9),
+		local(12, 29, 9),
+		local(12, 30, 9),
+		time.Date(2017, 1, 2, 9, 0, 0, 0, time.Local),
+	}
+	now := local(12, 23, 9)
+	for _, e := range expected {
+		now = s.Next(now)
+		c.Check(now, check.Equals, e)
+	}
+}
@@ -78,3 +78,25 @@ func (tg *TimeGlob) adjustMinutesSeconds(base time.Time, minute, second int) tim
 
 	return result
 }
+
+func addMilliseconds(base time.Time, millisecond int) time.Time {
+	// Add milliseconds to an even second, without normalizing. Timezone
+	// transitions happen on even seconds, so this can't cross one.
+
+	if base == UNKNOWN || millisecond < 0 || millisecond > 999 {
+		return UNKNOWN
+	}
+
+	return base.Add(time.Duration(millisecond) * time.Millisecond)
+}
+
+func spanBefore(start time.Time, length time.Duration, t time.Time) bool {
+	// Does the span of length from start end at, or before t? Spans with an
+	// unknown start are never skipped, since later parts of them may exist.
+	return start != UNKNOWN && !t.Before(start.Add(length))
+}
+
+func spanAfter(start, t time.Time) bool {
+	// Does a span which begins at start begin after t?
+	return start != UNKNOWN && t.Before(start)
+}
@@ -97,3 +97,23 @@ func (suite *MySuite) TestAdjustMinutesSeconds(c *check.C) {
 	result = tg.adjustMinutesSeconds(base, 5, 61)
 	c.Check(result, check.Equals, UNKNOWN)
 }
+
+func (suite *MySuite) TestAddMilliseconds(c *check.C) {
+	tg, err := Parse("2010/1/1 America/New_York")
+	c.Assert(err, check.IsNil)
+	base := tg.dateNoNormalize(2015, 2, 3, 4, 5, 6)
+
+	// Valid
+	result := addMilliseconds(base, 0)
+	c.Check(result, check.Equals, base)
+
+	result = addMilliseconds(base, 999)
+	c.Check(result, check.Equals, base.Add(999*time.Millisecond))
+
+	// Out of bounds.
+	result = addMilliseconds(base, 1000)
+	c.Check(result, check.Equals, UNKNOWN)
+
+	result = addMilliseconds(UNKNOWN, 5)
+	c.Check(result, check.Equals, UNKNOWN)
+}
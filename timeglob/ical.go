@@ -0,0 +1,281 @@
+package timeglob
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// The number of matches written as separate events, if ICalendarOptions.Count
+// isn't set.
+const DEFAULT_ICAL_COUNT = 10
+
+// How many years of timezone transitions to describe for an event with an
+// RRULE, which repeats forever.
+const ICAL_TIMEZONE_YEARS = 10
+
+// The longest content line, in octets, before it's folded.
+const icalLineLength = 75
+
+// Options for writing a TimeGlob as an iCalendar document.
+type ICalendarOptions struct {
+	// The SUMMARY of each event.
+	Summary string
+
+	// How long each event lasts. The default is 0, for events which mark an
+	// instant.
+	Duration time.Duration
+
+	// Events start at the first match at, or after this. The default is the
+	// current time.
+	Start time.Time
+
+	// How many matches to write when the glob can't be written as an RRULE,
+	// or Discrete is set. The default is DEFAULT_ICAL_COUNT.
+	Count int
+
+	// Always write separate events for the next Count matches, instead of a
+	// single repeating event.
+	Discrete bool
+
+	// The UID of the repeating event, and the prefix of UIDs for separate
+	// events. The default is derived from the glob.
+	UID string
+
+	// Source of the current time, used for DTSTAMP and the default Start. If
+	// nil, RealClock is used.
+	Clock Clock
+}
+
+func (tg *TimeGlob) ToICalendar(options ICalendarOptions) (string, error) {
+	// Write a VCALENDAR document, which calendar applications can subscribe
+	// to. If the glob can be written as an RRULE, this holds a single
+	// repeating VEVENT. Otherwise, it holds a VEVENT for each of the next
+	// Count matches.
+	//
+	// Globs in a named timezone include a VTIMEZONE describing its offsets and
+	// transitions. Globs in UTC are written in UTC, and globs in Local use
+	// floating times, which calendar applications show in the viewer's
+	// timezone.
+
+	clock := options.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+	now := clock.Now()
+
+	start := options.Start
+	if start.IsZero() {
+		start = now
+	}
+
+	count := options.Count
+	if count <= 0 {
+		count = DEFAULT_ICAL_COUNT
+	}
+
+	uid := options.UID
+	if uid == "" {
+		uid = tg.icalUID()
+	}
+
+	w := &icalWriter{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//DonGar//go-timeglob//EN")
+	w.line("CALSCALE:GREGORIAN")
+
+	var rrule string
+	var err error
+	if !options.Discrete {
+		rrule, err = tg.ToRRule(start)
+	}
+
+	var events []time.Time
+	if options.Discrete || err != nil {
+		events = tg.NextN(start.Add(-time.Nanosecond), count)
+	} else {
+		events = []time.Time{tg.Next(start.Add(-time.Nanosecond))}
+	}
+	if len(events) == 0 {
+		return "", fmt.Errorf("TimeGlob has no matches after %s", start)
+	}
+
+	if tg.location != time.UTC && tg.location != time.Local {
+		through := events[len(events)-1]
+		if rrule != "" {
+			through = through.AddDate(ICAL_TIMEZONE_YEARS, 0, 0)
+		}
+		w.timezone(tg.location, events[0], through.Add(options.Duration))
+	}
+
+	stamp := "DTSTAMP:" + now.UTC().Format("20060102T150405Z")
+
+	for _, event := range events {
+		event = event.In(tg.location)
+
+		w.line("BEGIN:VEVENT")
+		if rrule != "" {
+			w.line("UID:" + uid)
+		} else {
+			w.line("UID:" + uid + "-" + event.UTC().Format("20060102T150405Z"))
+		}
+		w.line(stamp)
+		w.line(formatICalTime("DTSTART", event))
+		w.line("DURATION:" + formatICalDuration(options.Duration))
+		if rrule != "" {
+			// ToRRule returns DTSTART and RRULE lines.
+			w.line(rrule[strings.Index(rrule, "\n")+1:])
+		}
+		if options.Summary != "" {
+			w.line("SUMMARY:" + escapeICalText(options.Summary))
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return w.String(), nil
+}
+
+func (tg *TimeGlob) icalUID() string {
+	// A UID which is stable for equivalent globs.
+
+	h := fnv.New64a()
+	fmt.Fprint(h, tg.year, tg.month, tg.day, tg.hour, tg.minute, tg.second, tg.millisecond, tg.location)
+
+	// Only globs with weekdays include them, so other UIDs don't change.
+	if tg.weekday != nil || tg.rules != nil {
+		fmt.Fprint(h, tg.weekday, tg.rules)
+	}
+	return fmt.Sprintf("%016x@timeglob", h.Sum64())
+}
+
+// Builds an iCalendar document from content lines.
+type icalWriter struct {
+	strings.Builder
+}
+
+func (w *icalWriter) line(text string) {
+	// Write a content line, ending in CRLF, and folded so no line is longer
+	// than icalLineLength octets. Folds never split a UTF-8 sequence.
+
+	// Folded lines start with a space, which counts towards their length.
+	limit := icalLineLength
+	for len(text) > limit {
+		cut := limit
+		for text[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(text[:cut])
+		w.WriteString("\r\n ")
+		text = text[cut:]
+		limit = icalLineLength - 1
+	}
+	w.WriteString(text)
+	w.WriteString("\r\n")
+}
+
+func (w *icalWriter) timezone(loc *time.Location, from, through time.Time) {
+	// Write a VTIMEZONE for loc, with a STANDARD or DAYLIGHT component for the
+	// offset in effect at from, and each transition until through.
+
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + loc.String())
+
+	t := from.In(loc)
+	zoneStart, _ := t.ZoneBounds()
+	if zoneStart.IsZero() {
+		// The offset has never changed.
+		w.observance(t, t)
+	} else {
+		w.observance(zoneStart.Add(-time.Nanosecond).In(loc), zoneStart.In(loc))
+	}
+
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(through) {
+			break
+		}
+		w.observance(end.Add(-time.Nanosecond).In(loc), end.In(loc))
+		t = end.In(loc)
+	}
+
+	w.line("END:VTIMEZONE")
+}
+
+func (w *icalWriter) observance(before, after time.Time) {
+	// Write a STANDARD or DAYLIGHT component for the change from the offset at
+	// before, to the offset at after. Its DTSTART is in the offset before.
+
+	_, from := before.Zone()
+	name, to := after.Zone()
+
+	kind := "STANDARD"
+	if after.IsDST() {
+		kind = "DAYLIGHT"
+	}
+
+	onset := after.In(time.FixedZone("", from))
+	if before.Equal(after) {
+		// There's no transition, so the offset applies from the start.
+		onset = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	w.line("BEGIN:" + kind)
+	w.line("DTSTART:" + onset.Format("20060102T150405"))
+	w.line("TZOFFSETFROM:" + formatICalOffset(from))
+	w.line("TZOFFSETTO:" + formatICalOffset(to))
+	w.line("TZNAME:" + escapeICalText(name))
+	w.line("END:" + kind)
+}
+
+func formatICalOffset(seconds int) string {
+	// Write a UTC offset, like -0500, or +053000 if it has seconds.
+
+	sign := "+"
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+
+	text := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		text += fmt.Sprintf("%02d", seconds%60)
+	}
+	return text
+}
+
+func formatICalDuration(d time.Duration) string {
+	// Write a non-negative duration, like PT1H30M, rounded to the second.
+
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds <= 0 {
+		return "PT0S"
+	}
+
+	text := "P"
+	if days := seconds / 86400; days > 0 {
+		text += fmt.Sprintf("%dD", days)
+		seconds %= 86400
+	}
+	if seconds > 0 {
+		text += "T"
+		if h := seconds / 3600; h > 0 {
+			text += fmt.Sprintf("%dH", h)
+		}
+		if m := seconds / 60 % 60; m > 0 {
+			text += fmt.Sprintf("%dM", m)
+		}
+		if s := seconds % 60; s > 0 {
+			text += fmt.Sprintf("%dS", s)
+		}
+	}
+	return text
+}
+
+func escapeICalText(text string) string {
+	// Escape a TEXT value.
+
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
@@ -0,0 +1,187 @@
+package timeglob_test
+
+import (
+	"github.com/DonGar/go-timeglob/timeglob"
+	"github.com/DonGar/go-timeglob/timeglob/timeglobtest"
+	"gopkg.in/check.v1"
+	"strings"
+	"time"
+)
+
+// iCalendar tests use timeglobtest's FakeClock for DTSTAMP, so they live
+// beside the Ticker tests, with their own suite.
+type ICalendarSuite struct{}
+
+var _ = check.Suite(&ICalendarSuite{})
+
+func icalLines(c *check.C, tg *timeglob.TimeGlob, options timeglob.ICalendarOptions) []string {
+	// Write the glob as iCalendar, and return its unfolded content lines.
+
+	if options.Clock == nil {
+		options.Clock = timeglobtest.NewFakeClock(time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC))
+	}
+
+	text, err := tg.ToICalendar(options)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.HasSuffix(text, "\r\n"), check.Equals, true)
+
+	for _, line := range strings.Split(text, "\r\n") {
+		c.Check(len(line) <= 75, check.Equals, true, check.Commentf(line))
+	}
+
+	text = strings.ReplaceAll(text, "\r\n ", "")
+	return strings.Split(strings.TrimSuffix(text, "\r\n"), "\r\n")
+}
+
+func (suite *ICalendarSuite) TestICalendarRRule(c *check.C) {
+	tg, err := timeglob.Parse("*/1,15 9:30 UTC")
+	c.Assert(err, check.IsNil)
+
+	lines := icalLines(c, tg, timeglob.ICalendarOptions{
+		Summary:  "Maintenance; patching, reboots",
+		Duration: 90 * time.Minute,
+		UID:      "maintenance@example.com",
+	})
+
+	c.Check(lines, check.DeepEquals, []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//DonGar//go-timeglob//EN",
+		"CALSCALE:GREGORIAN",
+		"BEGIN:VEVENT",
+		"UID:maintenance@example.com",
+		"DTSTAMP:20160101T120000Z",
+		"DTSTART:20160115T093000Z",
+		"DURATION:PT1H30M",
+		"RRULE:FREQ=MONTHLY;BYMONTHDAY=1,15;BYHOUR=9;BYMINUTE=30;BYSECOND=0",
+		"SUMMARY:Maintenance\\; patching\\, reboots",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	})
+}
+
+func (suite *ICalendarSuite) TestICalendarDiscrete(c *check.C) {
+	// Years can't be written as an RRULE, so each match is a separate event.
+	tg, err := timeglob.Parse("2016,2018/1/1 0:00 UTC")
+	c.Assert(err, check.IsNil)
+
+	lines := icalLines(c, tg, timeglob.ICalendarOptions{UID: "new-year"})
+	c.Check(lines[4:], check.DeepEquals, []string{
+		"BEGIN:VEVENT",
+		"UID:new-year-20180101T000000Z",
+		"DTSTAMP:20160101T120000Z",
+		"DTSTART:20180101T000000Z",
+		"DURATION:PT0S",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	})
+
+	// Discrete writes Count events, even if an RRULE would do.
+	tg, err = timeglob.Parse("*:00 UTC")
+	c.Assert(err, check.IsNil)
+
+	start := time.Date(2016, 1, 1, 9, 0, 0, 0, time.UTC)
+	lines = icalLines(c, tg, timeglob.ICalendarOptions{Start: start, Count: 3, Discrete: true})
+
+	starts := []string{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "DTSTART") {
+			starts = append(starts, line)
+		}
+		c.Check(strings.HasPrefix(line, "RRULE"), check.Equals, false)
+	}
+	c.Check(starts, check.DeepEquals, []string{
+		"DTSTART:20160101T090000Z",
+		"DTSTART:20160101T100000Z",
+		"DTSTART:20160101T110000Z",
+	})
+
+	// Without matches, there's nothing to write.
+	tg, err = timeglob.Parse("2015/1/1 0:00 UTC")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToICalendar(timeglob.ICalendarOptions{Start: start})
+	c.Check(err, check.ErrorMatches, "TimeGlob has no matches after .*")
+}
+
+func (suite *ICalendarSuite) TestICalendarTimezone(c *check.C) {
+	tg, err := timeglob.Parse("9:00 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	lines := icalLines(c, tg, timeglob.ICalendarOptions{
+		Start: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+		UID:   "daily",
+	})
+
+	text := strings.Join(lines, "\n")
+	c.Check(text, check.Matches, `(?s).*
+BEGIN:VTIMEZONE
+TZID:America/New_York
+BEGIN:STANDARD
+DTSTART:20151101T020000
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+TZNAME:EST
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:20160313T020000
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+TZNAME:EDT
+END:DAYLIGHT
+.*
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:daily
+DTSTAMP:20160101T120000Z
+DTSTART;TZID=America/New_York:20160101T090000
+DURATION:PT0S
+RRULE:FREQ=DAILY;BYHOUR=9;BYMINUTE=0;BYSECOND=0
+END:VEVENT
+END:VCALENDAR`)
+
+	// Transitions are described for 10 years.
+	c.Check(strings.Count(text, "BEGIN:DAYLIGHT"), check.Equals, 10)
+	c.Check(strings.Count(text, "BEGIN:STANDARD"), check.Equals, 11)
+
+	// A timezone without transitions has a single offset.
+	tg, err = timeglob.Parse("9:00 Etc/GMT-5")
+	c.Assert(err, check.IsNil)
+	lines = icalLines(c, tg, timeglob.ICalendarOptions{})
+	c.Check(strings.Join(lines, "\n"), check.Matches, `(?s).*
+BEGIN:STANDARD
+DTSTART:19700101T000000
+TZOFFSETFROM:\+0500
+TZOFFSETTO:\+0500
+TZNAME:\+05
+END:STANDARD
+END:VTIMEZONE
+.*`)
+
+	// Fixed offsets have no TZID other calendars know, so each event is
+	// listed, with the TZID quoted around its colon.
+	tg, err = timeglob.Parse("9:00 +05:30")
+	c.Assert(err, check.IsNil)
+	lines = icalLines(c, tg, timeglob.ICalendarOptions{Count: 2})
+	text = strings.Join(lines, "\n")
+	c.Check(text, check.Matches, `(?s).*
+TZID:\+05:30
+.*
+DTSTART;TZID="\+05:30":\d{8}T090000
+.*
+DTSTART;TZID="\+05:30":\d{8}T090000
+.*`)
+	c.Check(strings.Contains(text, "RRULE"), check.Equals, false)
+}
+
+func (suite *ICalendarSuite) TestICalendarFolding(c *check.C) {
+	tg, err := timeglob.Parse("9:00")
+	c.Assert(err, check.IsNil)
+
+	summary := strings.Repeat("Überprüfung ", 20)
+	lines := icalLines(c, tg, timeglob.ICalendarOptions{Summary: summary})
+
+	// Local globs use floating times, without a VTIMEZONE.
+	c.Check(lines[4], check.Equals, "BEGIN:VEVENT")
+	c.Check(lines[7], check.Matches, `DTSTART:\d{8}T090000`)
+	c.Check(lines[10], check.Equals, "SUMMARY:"+summary)
+}
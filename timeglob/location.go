@@ -0,0 +1,62 @@
+package timeglob
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Looks up a timezone by name, in the same way as time.LoadLocation.
+type LocationLoader func(name string) (*time.Location, error)
+
+func ZoneinfoZipLoader(data []byte) (LocationLoader, error) {
+	// Create a LocationLoader which reads timezones from the contents of a
+	// zoneinfo.zip file, such as $GOROOT/lib/time/zoneinfo.zip, instead of the
+	// host's timezone database. Embedding the file gives the same results on
+	// every host, including minimal containers without any zoneinfo files.
+	// (To fall back to an embedded database only when the host has none,
+	// import time/tzdata and use the default loader instead.)
+	//
+	// "UTC" and "Local" are handled as time.LoadLocation would.
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	loader := func(name string) (*time.Location, error) {
+		switch name {
+		case "", "UTC":
+			return time.UTC, nil
+		case "Local":
+			return time.Local, nil
+		}
+
+		f, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown time zone %s", name)
+		}
+
+		reader, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		tzdata, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		return time.LoadLocationFromTZData(name, tzdata)
+	}
+
+	return loader, nil
+}
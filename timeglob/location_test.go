@@ -0,0 +1,170 @@
+package timeglob
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"gopkg.in/check.v1"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+func syntheticZone(c *check.C, name string, std, dst int, transitions []time.Time) *time.Location {
+	// Build a timezone from TZif (version 1) data, starting in standard time
+	// and alternating between standard and daylight time at each transition.
+	// Offsets are in seconds east of UTC.
+
+	buf := &bytes.Buffer{}
+	write := func(data interface{}) {
+		c.Assert(binary.Write(buf, binary.BigEndian, data), check.IsNil)
+	}
+
+	abbreviations := []byte("STD\x00DST\x00")
+
+	buf.WriteString("TZif")
+	buf.Write(make([]byte, 16))
+
+	// isutcnt, isstdcnt, leapcnt, timecnt, typecnt, charcnt
+	write([]uint32{0, 0, 0, uint32(len(transitions)), 2, uint32(len(abbreviations))})
+
+	for _, t := range transitions {
+		write(int32(t.Unix()))
+	}
+	for i := range transitions {
+		write(uint8((i + 1) % 2))
+	}
+
+	// Zone types: offset, isdst, abbreviation index.
+	write(int32(std))
+	write([]uint8{0, 0})
+	write(int32(dst))
+	write([]uint8{1, 4})
+
+	buf.Write(abbreviations)
+
+	loc, err := time.LoadLocationFromTZData(name, buf.Bytes())
+	c.Assert(err, check.IsNil)
+	return loc
+}
+
+func (suite *MySuite) TestParseLocationLoader(c *check.C) {
+	// Lord Howe Island style daylight savings, which only moves 30 minutes.
+	howe := syntheticZone(c, "Test/Howe", (10*60+30)*60, 11*60*60, []time.Time{
+		time.Date(2016, 10, 1, 15, 30, 0, 0, time.UTC),
+		time.Date(2017, 4, 1, 15, 0, 0, 0, time.UTC),
+	})
+
+	options := ParseOptions{
+		LocationLoader: func(name string) (*time.Location, error) {
+			if name == "Test/Howe" {
+				return howe, nil
+			}
+			return nil, fmt.Errorf("Unknown time zone %s", name)
+		},
+	}
+
+	tg, err := ParseWithOptions("*:15 Test/Howe", options)
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.Equals, howe)
+
+	// There is no 2:15 AM on the day daylight savings starts.
+	now := tg.dateNoNormalize(2016, 10, 2, 1, 20, 0)
+	validateNext(c, tg, now, tg.dateNoNormalize(2016, 10, 2, 3, 15, 0))
+	c.Check(tg.Next(now).Sub(now), check.Equals, 85*time.Minute)
+
+	// Names the loader doesn't know are rejected, but fixed offsets are not.
+	tg, err = ParseWithOptions("*:15 America/New_York", options)
+	c.Check(tg, check.IsNil)
+	c.Check(err, check.NotNil)
+
+	tg, err = ParseWithOptions("*:15 +05:30", options)
+	c.Check(err, check.IsNil)
+	c.Check(tg.location, check.DeepEquals, time.FixedZone("+05:30", (5*60+30)*60))
+
+	// The zero value uses time.LoadLocation.
+	tg, err = ParseWithOptions("*:15 America/New_York", ParseOptions{})
+	c.Check(err, check.IsNil)
+	c.Check(tg.location.String(), check.Equals, "America/New_York")
+}
+
+func (suite *MySuite) TestParseFormatsLocationLoader(c *check.C) {
+	// Cron, RRULE and OnCalendar find timezones in the same way as globs.
+	test := syntheticZone(c, "Test/Zone", 2*60*60, 3*60*60, nil)
+	options := ParseOptions{
+		LocationLoader: func(name string) (*time.Location, error) {
+			if name == "Test/Zone" {
+				return test, nil
+			}
+			return nil, fmt.Errorf("Unknown time zone %s", name)
+		},
+	}
+	fixed := time.FixedZone("+05:30", (5*60+30)*60)
+
+	tg, err := ParseCronWithOptions("CRON_TZ=Test/Zone 0 9 * * *", CRON_STANDARD, options)
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.Equals, test)
+
+	tg, err = ParseCron("CRON_TZ=+05:30 0 9 * * *", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.DeepEquals, fixed)
+
+	_, err = ParseCronWithOptions("CRON_TZ=America/New_York 0 9 * * *", CRON_STANDARD, options)
+	c.Check(err, check.ErrorMatches, "Not a valid cron expression.*Unknown time zone America/New_York.*")
+
+	tg, err = ParseRRuleWithOptions("DTSTART;TZID=Test/Zone:20160101T090000\nRRULE:FREQ=DAILY", options)
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.Equals, test)
+
+	tg, err = ParseRRule("DTSTART;TZID=\"+05:30\":20160101T090000\nRRULE:FREQ=DAILY")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.DeepEquals, fixed)
+
+	tg, err = ParseOnCalendarWithOptions("*-*-* 09:00:00 Test/Zone", options)
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.Equals, test)
+
+	tg, err = ParseOnCalendar("*-*-* 09:00:00 +05:30")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.location, check.DeepEquals, fixed)
+
+	_, err = ParseOnCalendarWithOptions("*-*-* 09:00:00 America/New_York", options)
+	c.Check(err, check.NotNil)
+}
+
+func (suite *MySuite) TestZoneinfoZipLoader(c *check.C) {
+	data, err := os.ReadFile(filepath.Join(runtime.GOROOT(), "lib", "time", "zoneinfo.zip"))
+	if err != nil {
+		c.Skip("zoneinfo.zip not available: " + err.Error())
+	}
+
+	loader, err := ZoneinfoZipLoader(data)
+	c.Assert(err, check.IsNil)
+
+	loc, err := loader("America/New_York")
+	c.Assert(err, check.IsNil)
+	c.Check(loc.String(), check.Equals, "America/New_York")
+
+	loc, err = loader("UTC")
+	c.Check(err, check.IsNil)
+	c.Check(loc, check.Equals, time.UTC)
+
+	loc, err = loader("Local")
+	c.Check(err, check.IsNil)
+	c.Check(loc, check.Equals, time.Local)
+
+	_, err = loader("Bad/Local")
+	c.Check(err, check.NotNil)
+
+	tg, err := ParseWithOptions("2016/11/6 *:12 America/New_York", ParseOptions{LocationLoader: loader})
+	c.Assert(err, check.IsNil)
+
+	// Daylight savings rules come from the zip file.
+	now := tg.dateNoNormalize(2016, 11, 6, 0, 0, 0)
+	c.Check(tg.Count(now, now.Add(24*time.Hour)), check.Equals, 24)
+
+	// Not a zip file.
+	_, err = ZoneinfoZipLoader([]byte("Not a zip file"))
+	c.Check(err, check.NotNil)
+}
@@ -0,0 +1,48 @@
+package timeglob
+
+import (
+	"time"
+)
+
+func (tg *TimeGlob) Matches(t time.Time) bool {
+	// Is t an exact match for the glob, to the nanosecond?
+
+	if t == UNKNOWN {
+		return false
+	}
+
+	local := t.In(tg.location)
+	if local.Nanosecond()%int(time.Millisecond) != 0 ||
+		!inValues(tg.year, local.Year()) ||
+		!inValues(tg.month, int(local.Month())) ||
+		!tg.matchesDay(local.Year(), int(local.Month()), local.Day()) ||
+		!inValues(tg.hour, local.Hour()) ||
+		!inValues(tg.minute, local.Minute()) ||
+		!inValues(tg.second, local.Second()) ||
+		!inValues(tg.millisecond, local.Nanosecond()/int(time.Millisecond)) {
+		return false
+	}
+
+	// Near a timezone transition, some wall clock times don't exist, so let
+	// Prev decide. Elsewhere matching fields are enough.
+	zoneStart, zoneEnd := local.ZoneBounds()
+	if local.Sub(zoneStart) > 24*time.Hour && (zoneEnd.IsZero() || zoneEnd.Sub(local) > 24*time.Hour) {
+		return true
+	}
+
+	return tg.Prev(t).Equal(t)
+}
+
+func inValues(values []int, v int) bool {
+	// Is v in the list of values, or is the list a wildcard?
+
+	if values == nil {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
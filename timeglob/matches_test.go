@@ -0,0 +1,43 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestMatches(c *check.C) {
+	tg, err := Parse("*/1,15 9:30 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	match := time.Date(2016, 3, 15, 9, 30, 0, 0, tg.location)
+	c.Check(tg.Matches(match), check.Equals, true)
+	c.Check(tg.Matches(match.UTC()), check.Equals, true)
+
+	c.Check(tg.Matches(match.Add(time.Nanosecond)), check.Equals, false)
+	c.Check(tg.Matches(match.Add(-time.Nanosecond)), check.Equals, false)
+	c.Check(tg.Matches(match.AddDate(0, 0, 1)), check.Equals, false)
+	c.Check(tg.Matches(UNKNOWN), check.Equals, false)
+
+	// Fractional seconds.
+	tg, err = Parse("*:*:*.500 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.Matches(time.Date(2016, 1, 1, 0, 0, 1, 500*1000000, time.UTC)), check.Equals, true)
+	c.Check(tg.Matches(time.Date(2016, 1, 1, 0, 0, 1, 0, time.UTC)), check.Equals, false)
+}
+
+func (suite *MySuite) TestMatchesTransitions(c *check.C) {
+	tg, err := Parse("*:12 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	// 1:12 AM happens twice, and both match.
+	first := time.Date(2016, 11, 6, 1, 12, 0, 0, tg.location)
+	c.Check(tg.Matches(first), check.Equals, true)
+	c.Check(tg.Matches(first.Add(time.Hour)), check.Equals, true)
+	c.Check(tg.Matches(first.Add(30*time.Minute)), check.Equals, false)
+
+	// Matches agrees with Prev on either side of a transition.
+	for _, t := range tg.Between(time.Date(2016, 3, 12, 0, 0, 0, 0, time.UTC), time.Date(2016, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		c.Check(tg.Matches(t), check.Equals, true, check.Commentf("%s", t))
+		c.Check(tg.Matches(t.Add(time.Minute)), check.Equals, false, check.Commentf("%s", t))
+	}
+}
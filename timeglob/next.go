@@ -18,13 +18,13 @@ func (tg *TimeGlob) Next(now time.Time) time.Time {
 	return result
 }
 
-func (tg *TimeGlob) expandNext(now time.Time) (years, months, days, hours, minutes, seconds []int) {
+func (tg *TimeGlob) expandNext(now time.Time) (years, months, days, hours, minutes, seconds, milliseconds []int) {
 	// Expand wildcard values out to explict lists of values.
 
 	years = tg.year
 	if len(years) == 0 {
 		// Expand years wildcard in a limited way to avoid searching forever.
-		years = intRange(now.Year(), now.Year()+YEAR_SEARCH_DEPTH)
+		years = intRange(now.Year(), now.Year()+tg.yearSearchDepth())
 	}
 
 	months = tg.month
@@ -33,7 +33,7 @@ func (tg *TimeGlob) expandNext(now time.Time) (years, months, days, hours, minut
 	}
 
 	days = tg.day
-	if len(days) == 0 {
+	if len(days) == 0 || !tg.listsDays() {
 		days = intRange(1, 32)
 	}
 
@@ -52,11 +52,16 @@ func (tg *TimeGlob) expandNext(now time.Time) (years, months, days, hours, minut
 		seconds = intRange(0, 61)
 	}
 
-	return years, months, days, hours, minutes, seconds
+	milliseconds = tg.millisecond
+	if len(milliseconds) == 0 {
+		milliseconds = intRange(0, 999)
+	}
+
+	return years, months, days, hours, minutes, seconds, milliseconds
 }
 
 func (tg *TimeGlob) nextDate(now time.Time) time.Time {
-	years, months, days, hours, minutes, seconds := tg.expandNext(now)
+	years, months, days, hours, minutes, seconds, milliseconds := tg.expandNext(now)
 
 	dateNow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tg.location)
 
@@ -67,16 +72,31 @@ func (tg *TimeGlob) nextDate(now time.Time) time.Time {
 				// For performance validate that each date might be parse of a valid
 				// result before searching inside the date.
 				searchDate := tg.dateNoNormalize(year, month, day, 0, 0, 0)
-				if searchDate == UNKNOWN || searchDate.Before(dateNow) {
+				if searchDate == UNKNOWN || searchDate.Before(dateNow) || !tg.matchesDay(year, month, day) {
 					continue
 				}
 
 				for _, hour := range hours {
-					for _, minute := range minutes {
+					// Hours, minutes and seconds which are over by now are skipped
+					// without stepping through them, so wildcards stay fast.
+					hourMinutes := minutes
+					if spanBefore(tg.dateNoNormalize(year, month, day, hour, 0, 0), time.Hour, now) {
+						hourMinutes = nil
+					}
+					for _, minute := range hourMinutes {
+						if spanBefore(tg.dateNoNormalize(year, month, day, hour, minute, 0), time.Minute, now) {
+							continue
+						}
 						for _, second := range seconds {
-							result := tg.dateNoNormalize(year, month, day, hour, minute, second)
-							if result != UNKNOWN && now.Before(result) {
-								return result
+							base := tg.dateNoNormalize(year, month, day, hour, minute, second)
+							if base == UNKNOWN || spanBefore(base, time.Second, now) {
+								continue
+							}
+							for _, millisecond := range milliseconds {
+								result := addMilliseconds(base, millisecond)
+								if result != UNKNOWN && now.Before(result) {
+									return result
+								}
 							}
 						}
 					}
@@ -90,12 +110,18 @@ func (tg *TimeGlob) nextDate(now time.Time) time.Time {
 						base := tg.dateNoNormalize(year, month, day, hour, 0, 0)
 						advanced := base.Add(time.Hour)
 
-						if base.Hour() == advanced.Hour() {
+						if base.Hour() == advanced.Hour() && !spanBefore(advanced, time.Hour, now) {
 							for _, minute := range minutes {
 								for _, second := range seconds {
-									result := tg.adjustMinutesSeconds(advanced, minute, second)
-									if result != UNKNOWN && now.Before(result) {
-										return result
+									adjusted := tg.adjustMinutesSeconds(advanced, minute, second)
+									if adjusted == UNKNOWN || spanBefore(adjusted, time.Second, now) {
+										continue
+									}
+									for _, millisecond := range milliseconds {
+										result := addMilliseconds(adjusted, millisecond)
+										if result != UNKNOWN && now.Before(result) {
+											return result
+										}
 									}
 								}
 							}
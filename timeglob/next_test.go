@@ -317,6 +317,33 @@ func (suite *MySuite) TestNextComma(c *check.C) {
 		})
 }
 
+func (suite *MySuite) TestNextMillisecond(c *check.C) {
+	tg, err := Parse("*:*:59.0,250,500,750 UTC")
+	c.Assert(err, check.IsNil)
+
+	validateNextSequence(c, tg,
+		tg.dateNoNormalize(2016, 12, 31, 23, 59, 58),
+		[]time.Time{
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59),
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(250 * time.Millisecond),
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(500 * time.Millisecond),
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(750 * time.Millisecond),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 59),
+		})
+
+	// Fractions of a millisecond.
+	now := tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(time.Microsecond)
+	validateNext(c, tg, now, tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(250*time.Millisecond))
+}
+
+func (suite *MySuite) TestNextFixedZone(c *check.C) {
+	tg, err := Parse("19:37 +05:30")
+	c.Assert(err, check.IsNil)
+
+	now := time.Date(2015, 1, 1, 12, 0, 0, 0, time.UTC)
+	validateNext(c, tg, now, time.Date(2015, 1, 1, 14, 7, 0, 0, time.UTC))
+}
+
 func (suite *MySuite) TestNextPerformance(c *check.C) {
 	// Demonstrate performance issues.
 
@@ -331,4 +358,16 @@ func (suite *MySuite) TestNextPerformance(c *check.C) {
 			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59),
 			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0),
 		})
+
+	// This used to step through every millisecond since midnight.
+	tg, err = Parse("*:*:*.* UTC")
+	c.Assert(err, check.IsNil)
+
+	validateNextSequence(c, tg,
+		tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(998*time.Millisecond),
+		[]time.Time{
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(999 * time.Millisecond),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(time.Millisecond),
+		})
 }
@@ -0,0 +1,266 @@
+package timeglob
+
+import (
+	"time"
+)
+
+func (tg *TimeGlob) NextN(now time.Time, n int) []time.Time {
+	// Find the next n times which match the glob and are after now, in order.
+	// Returns fewer than n results if there aren't enough matches.
+
+	from := now.In(tg.location)
+	result := []time.Time{}
+	if n <= 0 {
+		return result
+	}
+
+	tg.searchDays(from, true, func(cd calendarDay) bool {
+		return tg.eachInDay(cd, from, func(t time.Time) bool {
+			if !from.Before(t) {
+				return true
+			}
+			result = append(result, t.In(now.Location()))
+			return len(result) < n
+		})
+	})
+
+	return result
+}
+
+func (tg *TimeGlob) PrevN(now time.Time, n int) []time.Time {
+	// Find the previous n times which match the glob and are before, or equal
+	// to now, closest first. Returns fewer than n results if there aren't
+	// enough matches.
+
+	from := now.In(tg.location)
+	result := []time.Time{}
+	if n <= 0 {
+		return result
+	}
+
+	tg.searchDays(from, false, func(cd calendarDay) bool {
+		return tg.eachInDayBack(cd, from, func(t time.Time) bool {
+			if from.Before(t) {
+				return true
+			}
+			result = append(result, t.In(now.Location()))
+			return len(result) < n
+		})
+	})
+
+	return result
+}
+
+func (tg *TimeGlob) Nth(now time.Time, k int) time.Time {
+	// Find the k-th match after now, so Nth(now, 1) is the same as Next(now).
+	// If k is negative, find the k-th match before or equal to now, so
+	// Nth(now, -1) is the same as Prev(now). Whole days are skipped
+	// arithmetically, instead of calling Next k times. Returns UNKNOWN if there
+	// isn't a match, or k is 0.
+
+	from := now.In(tg.location)
+
+	// Matches are never finer than a nanosecond, so this is the first
+	// possible match after now.
+	after := from.Add(time.Nanosecond)
+
+	result := UNKNOWN
+	if k > 0 {
+		tg.searchDays(from, true, func(cd calendarDay) bool {
+			count := tg.countInDay(cd, after, cd.end)
+			if k > count {
+				k -= count
+				return true
+			}
+			result = tg.indexInDay(cd, after, k-1)
+			return false
+		})
+	} else if k < 0 {
+		k = -k
+		tg.searchDays(from, false, func(cd calendarDay) bool {
+			count := tg.countInDay(cd, cd.start, after)
+			if k > count {
+				k -= count
+				return true
+			}
+			result = tg.indexInDay(cd, cd.start, count-k)
+			return false
+		})
+	}
+
+	if result != UNKNOWN {
+		result = result.In(now.Location())
+	}
+
+	return result
+}
+
+func (tg *TimeGlob) searchDays(from time.Time, forward bool, fn func(calendarDay) bool) {
+	// Call fn with each day which matches the date portion of the glob, moving
+	// forward or backward from the day containing from, until fn returns false.
+	//
+	// Year wildcards are searched YEAR_SEARCH_DEPTH years at a time (or
+	// WEEKDAY_YEAR_SEARCH_DEPTH with weekdays), and the search gives up after
+	// that many years without any matches.
+
+	step := 1
+	if !forward {
+		step = -1
+	}
+
+	for {
+		found := false
+		var last calendarDay
+
+		walk := func(cd calendarDay) bool {
+			last = cd
+			found = found || tg.countInDay(cd, cd.start, cd.end) > 0
+			return fn(cd)
+		}
+
+		lastYear := from.Year() + step*tg.yearSearchDepth()
+		if forward && !tg.eachDay(from, lastYear, walk) {
+			return
+		}
+		if !forward && !tg.eachDayBack(from, lastYear, walk) {
+			return
+		}
+
+		if !found || len(tg.year) != 0 {
+			return
+		}
+
+		from = time.Date(last.year, time.Month(last.month), last.day+step, 12, 0, 0, 0, tg.location)
+	}
+}
+
+func (tg *TimeGlob) eachDayBack(from time.Time, firstYear int, fn func(calendarDay) bool) bool {
+	// Call fn with each day which matches the date portion of the glob, in
+	// reverse order. Starts with the day containing from, and expands a year
+	// wildcard back through firstYear. Returns false if fn stopped the search
+	// early.
+
+	years, months, days, _, _, _, _ := tg.expandPrev(from)
+	if len(tg.year) == 0 {
+		years = intRange(from.Year(), firstYear)
+	}
+
+	fromKey := dateKey(from.Year(), int(from.Month()), from.Day())
+
+	for _, year := range years {
+		for _, month := range months {
+			for _, day := range days {
+				if dateKey(year, month, day) > fromKey {
+					continue
+				}
+
+				cd, ok := tg.calendarDay(year, month, day)
+				if !ok {
+					continue
+				}
+
+				if !fn(cd) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func (tg *TimeGlob) eachInDayBack(cd calendarDay, to time.Time, fn func(time.Time) bool) bool {
+	// Call fn with each match inside of a given day, in reverse order. This
+	// follows the same rules as prevDate. Hours, minutes and seconds which
+	// start after to are skipped, but fn may still be called with some
+	// matches after to. Returns false if fn stopped early.
+
+	_, _, _, hours, minutes, seconds, milliseconds := tg.expandPrev(cd.start)
+
+	for _, hour := range hours {
+
+		// Repeated hours are matched by hour wildcards, as in prevDate.
+		if len(tg.hour) == 0 {
+			base := tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, 0, 0)
+			advanced := base.Add(time.Hour)
+
+			if base.Hour() == advanced.Hour() && !spanAfter(advanced, to) {
+				for _, minute := range minutes {
+					for _, second := range seconds {
+						adjusted := tg.adjustMinutesSeconds(advanced, minute, second)
+						if adjusted == UNKNOWN || spanAfter(adjusted, to) {
+							continue
+						}
+						for _, millisecond := range milliseconds {
+							result := addMilliseconds(adjusted, millisecond)
+							if result != UNKNOWN && !fn(result) {
+								return false
+							}
+						}
+					}
+				}
+			}
+		}
+
+		if spanAfter(tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, 0, 0), to) {
+			continue
+		}
+		for _, minute := range minutes {
+			if spanAfter(tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, minute, 0), to) {
+				continue
+			}
+			for _, second := range seconds {
+				base := tg.dateNoNormalize(cd.year, cd.month, cd.day, hour, minute, second)
+				if base == UNKNOWN || spanAfter(base, to) {
+					continue
+				}
+				for _, millisecond := range milliseconds {
+					result := addMilliseconds(base, millisecond)
+					if result != UNKNOWN && !fn(result) {
+						return false
+					}
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func (tg *TimeGlob) indexInDay(cd calendarDay, from time.Time, index int) time.Time {
+	// Return the match inside of a day which comes index matches after the
+	// first match at, or after from. Regular days are calculated directly from
+	// the field values.
+
+	if tg.regularDay(cd) {
+		hours, minutes, seconds, milliseconds := tg.clockValues()
+		perSecond := len(milliseconds)
+		perMinute := len(seconds) * perSecond
+		perHour := len(minutes) * perMinute
+
+		index += tg.countBefore(from.Sub(cd.start))
+		if index < 0 || index >= len(hours)*perHour {
+			return UNKNOWN
+		}
+
+		base := tg.dateNoNormalize(cd.year, cd.month, cd.day,
+			hours[index/perHour],
+			minutes[index/perMinute%len(minutes)],
+			seconds[index/perSecond%len(seconds)])
+		return addMilliseconds(base, milliseconds[index%perSecond])
+	}
+
+	result := UNKNOWN
+	tg.eachInDay(cd, from, func(t time.Time) bool {
+		if t.Before(from) {
+			return true
+		}
+		if index == 0 {
+			result = t
+			return false
+		}
+		index--
+		return true
+	})
+	return result
+}
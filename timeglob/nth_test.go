@@ -0,0 +1,133 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func validateNthMatchesNextPrev(c *check.C, tg *TimeGlob, now time.Time, n int) {
+	// NextN, PrevN and Nth should agree with repeated calls to Next and Prev.
+
+	next := []time.Time{}
+	for t := tg.Next(now); t != UNKNOWN && len(next) < n; t = tg.Next(t) {
+		next = append(next, t)
+	}
+
+	prev := []time.Time{}
+	for t := tg.Prev(now); t != UNKNOWN && len(prev) < n; t = tg.Prev(t.Add(-time.Nanosecond)) {
+		prev = append(prev, t)
+	}
+
+	c.Check(tg.NextN(now, n), check.DeepEquals, next)
+	c.Check(tg.PrevN(now, n), check.DeepEquals, prev)
+
+	for k := 1; k <= n; k++ {
+		expected := UNKNOWN
+		if k <= len(next) {
+			expected = next[k-1]
+		}
+		c.Check(tg.Nth(now, k), check.Equals, expected)
+
+		expected = UNKNOWN
+		if k <= len(prev) {
+			expected = prev[k-1]
+		}
+		c.Check(tg.Nth(now, -k), check.Equals, expected)
+	}
+}
+
+func (suite *MySuite) TestNextN(c *check.C) {
+	tg, err := Parse("*/*/* 10:15 UTC")
+	c.Assert(err, check.IsNil)
+
+	now := tg.dateNoNormalize(2015, 12, 30, 10, 15, 0)
+	c.Check(tg.NextN(now, 3), check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2015, 12, 31, 10, 15, 0),
+		tg.dateNoNormalize(2016, 1, 1, 10, 15, 0),
+		tg.dateNoNormalize(2016, 1, 2, 10, 15, 0),
+	})
+
+	c.Check(tg.NextN(now, 0), check.DeepEquals, []time.Time{})
+
+	// Results are in the timezone of now.
+	c.Check(tg.NextN(now.In(time.FixedZone("X", 3600)), 1), check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2015, 12, 31, 10, 15, 0).In(time.FixedZone("X", 3600)),
+	})
+}
+
+func (suite *MySuite) TestPrevN(c *check.C) {
+	tg, err := Parse("*/*/* 10:15 UTC")
+	c.Assert(err, check.IsNil)
+
+	// Now is included.
+	now := tg.dateNoNormalize(2016, 1, 2, 10, 15, 0)
+	c.Check(tg.PrevN(now, 3), check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2016, 1, 2, 10, 15, 0),
+		tg.dateNoNormalize(2016, 1, 1, 10, 15, 0),
+		tg.dateNoNormalize(2015, 12, 31, 10, 15, 0),
+	})
+
+	c.Check(tg.PrevN(now, -1), check.DeepEquals, []time.Time{})
+}
+
+func (suite *MySuite) TestNthShort(c *check.C) {
+	tg, err := Parse("2015/12/25 19:37 UTC")
+	c.Assert(err, check.IsNil)
+
+	now := tg.dateNoNormalize(2014, 1, 1, 0, 0, 0)
+	c.Check(tg.NextN(now, 3), check.DeepEquals, []time.Time{
+		tg.dateNoNormalize(2015, 12, 25, 19, 37, 0),
+	})
+	c.Check(tg.Nth(now, 1), check.Equals, tg.dateNoNormalize(2015, 12, 25, 19, 37, 0))
+	c.Check(tg.Nth(now, 2), check.Equals, UNKNOWN)
+	c.Check(tg.Nth(now, 0), check.Equals, UNKNOWN)
+	c.Check(tg.Nth(now, -1), check.Equals, UNKNOWN)
+
+	// Globs which can never match.
+	tg, err = Parse("*/* 25:0 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.NextN(now, 3), check.DeepEquals, []time.Time{})
+	c.Check(tg.Nth(now, 3), check.Equals, UNKNOWN)
+	c.Check(tg.Nth(now, -3), check.Equals, UNKNOWN)
+}
+
+func (suite *MySuite) TestNthDaily(c *check.C) {
+	tg, err := Parse("3:00 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	// The 30th previous daily snapshot.
+	now := tg.dateNoNormalize(2016, 3, 20, 12, 0, 0)
+	c.Check(tg.Nth(now, -30), check.Equals, tg.dateNoNormalize(2016, 2, 20, 3, 0, 0))
+
+	// A match many years out.
+	c.Check(tg.Nth(now, 3650), check.Equals, tg.dateNoNormalize(2026, 3, 18, 3, 0, 0))
+}
+
+func (suite *MySuite) TestNthDense(c *check.C) {
+	tg, err := Parse("*:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	// A month of seconds, without enumerating them.
+	now := tg.dateNoNormalize(2016, 2, 1, 0, 0, 0)
+	c.Check(tg.Nth(now, 29*24*60*60), check.Equals, tg.dateNoNormalize(2016, 3, 1, 0, 0, 0))
+	c.Check(tg.Nth(now, -29*24*60*60), check.Equals, tg.dateNoNormalize(2016, 1, 3, 0, 0, 1))
+}
+
+func (suite *MySuite) TestNthCompareNextPrev(c *check.C) {
+	globs := []string{
+		"2015,2016/2,3/10,20 2,14:0,30:15,45 UTC",
+		"*/2/29 America/New_York",
+		"*/*/31 23:59:59 America/New_York",
+		"1,2:0,30 America/New_York",
+		"*:12 America/New_York",
+		"*:12:0,30.0,500 America/New_York",
+	}
+
+	for _, g := range globs {
+		tg, err := Parse(g)
+		c.Assert(err, check.IsNil)
+
+		validateNthMatchesNextPrev(c, tg, tg.dateNoNormalize(2016, 3, 12, 23, 30, 0), 30)
+		validateNthMatchesNextPrev(c, tg, tg.dateNoNormalize(2016, 11, 6, 1, 12, 0), 30)
+	}
+}
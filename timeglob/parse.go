@@ -2,6 +2,7 @@ package timeglob
 
 import (
 	"fmt"
+	"hash/fnv"
 	"regexp"
 	"sort"
 	"strconv"
@@ -9,24 +10,87 @@ import (
 	"time"
 )
 
+// Common timezone abbreviations, and their fixed offsets from UTC in hours.
+// These are only used if time.LoadLocation doesn't recognize the name.
+// Abbreviations which are also IANA timezone names, such as CET or EST, are
+// left out, so each name means the same thing with or without a timezone
+// database. UTC and GMT have the same offsets either way.
+var zoneAbbreviations = map[string]float64{
+	"GMT":  0,
+	"UTC":  0,
+	"WEST": 1,
+	"BST":  1,
+	"CEST": 2,
+	"EEST": 3,
+	"MSK":  3,
+	"JST":  9,
+	"KST":  9,
+	"AEST": 10,
+	"AEDT": 11,
+	"NZST": 12,
+	"NZDT": 13,
+	"AKST": -9,
+	"AKDT": -8,
+	"PST":  -8,
+	"PDT":  -7,
+	"MDT":  -6,
+	"CST":  -6,
+	"CDT":  -5,
+	"EDT":  -4,
+	"AST":  -4,
+	"ADT":  -3,
+	"NST":  -3.5,
+	"NDT":  -2.5,
+}
+
+// Options which control how globs are parsed. The zero value gives the same
+// results as Parse.
+type ParseOptions struct {
+	// Used to look up named timezones. If nil, time.LoadLocation is used.
+	LocationLoader LocationLoader
+
+	// Used to choose values for H fields, so each key gets its own stable
+	// values. Globs which use H fail to parse without a key.
+	Key string
+
+	// Named schedules, which ParseSchedule accepts in place of a glob, such
+	// as "Holidays" in "*/* 9:00 except Holidays". Ignored by Parse.
+	Schedules map[string]Schedule
+}
+
 func Parse(glob string) (*TimeGlob, error) {
+	return ParseWithOptions(glob, ParseOptions{})
+}
+
+func ParseWithOptions(glob string, options ParseOptions) (*TimeGlob, error) {
+	// Unions and exceptions aren't a single TimeGlob.
+	if strings.Contains(glob, "|") || exceptRegexp.MatchString(glob) {
+		return nil, fmt.Errorf("Not a valid TimeGlob: %s (use ParseSchedule for \"|\" and \"except\")", glob)
+	}
+
 	result := new()
-	sections := strings.SplitN(glob, " ", 3)
+	sections := strings.SplitN(glob, " ", 4)
 
 	if len(sections) > 0 {
-		if result.parseDate(sections[0]) {
+		if result.parseWeekday(sections[0]) {
 			sections = sections[1:]
 		}
 	}
 
 	if len(sections) > 0 {
-		if result.parseTime(sections[0]) {
+		if result.parseDate(sections[0], options.Key) {
 			sections = sections[1:]
 		}
 	}
 
 	if len(sections) > 0 {
-		if result.parseLocation(sections[0]) {
+		if result.parseTime(sections[0], options.Key) {
+			sections = sections[1:]
+		}
+	}
+
+	if len(sections) > 0 {
+		if result.parseLocation(sections[0], options.LocationLoader) {
 			sections = sections[1:]
 		}
 	}
@@ -38,9 +102,15 @@ func Parse(glob string) (*TimeGlob, error) {
 	return &result, nil
 }
 
-func parseIntList(blob string) []int {
+// A comma separated list of values and ranges, such as "1,3-5", in a glob.
+const intListPattern = `(?:[0-9]+(?:-[0-9]+)?|,)+`
+
+func parseIntList(blob string) ([]int, bool) {
+	// Parse a list matching intListPattern. Returns false if a range is
+	// backwards, or too large.
+
 	if blob == "*" || blob == "" {
-		return nil
+		return nil, true
 	}
 
 	sections := strings.Split(blob, ",")
@@ -51,11 +121,26 @@ func parseIntList(blob string) []int {
 			continue
 		}
 
-		val, err := strconv.ParseUint(s, 10, 32)
+		bounds := strings.SplitN(s, "-", 2)
+		low, err := strconv.ParseUint(bounds[0], 10, 32)
 		if err != nil {
 			panic(err)
 		}
-		values[int(val)] = true
+		high := low
+		if len(bounds) == 2 {
+			high, err = strconv.ParseUint(bounds[1], 10, 32)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		// No field goes past the year 9999, which also keeps ranges small.
+		if low > high || high > 9999 {
+			return nil, false
+		}
+		for val := low; val <= high; val++ {
+			values[int(val)] = true
+		}
 	}
 
 	// Convert map to sorted slice (nil if empty).
@@ -64,46 +149,199 @@ func parseIntList(blob string) []int {
 		result = append(result, key)
 	}
 	sort.Ints(result)
-	return result
+	return result, true
 }
 
-func (tg *TimeGlob) parseDate(glob string) bool {
-	re := regexp.MustCompile(`^(([0-9,]+|\*)/)?([0-9,]+|\*)/([0-9,]+|\*)$`)
+func parseHashList(blob, key, field string, begin, end int) ([]int, bool) {
+	// Parse a list, where "H" is a single value between begin and end
+	// inclusive, chosen by hashing the key and field name. This spreads
+	// identical globs with different keys across the range, while each key
+	// always gets the same value.
+
+	if blob != "H" {
+		return parseIntList(blob)
+	}
+
+	if key == "" {
+		return nil, false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(field))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	return []int{begin + int(h.Sum64()%uint64(end-begin+1))}, true
+}
+
+func parseMillisecondList(blob string) ([]int, bool) {
+	// Fractional seconds are decimal digits, so ".5" is 500 milliseconds. More
+	// than 3 digits is finer than we support.
+
+	if blob == "*" || blob == "" {
+		return parseIntList(blob)
+	}
+
+	sections := strings.Split(blob, ",")
+	for i, s := range sections {
+		if len(s) > 3 {
+			return nil, false
+		}
+		if s != "" {
+			sections[i] = s + strings.Repeat("0", 3-len(s))
+		}
+	}
+
+	return parseIntList(strings.Join(sections, ","))
+}
+
+func (tg *TimeGlob) parseDate(glob, key string) bool {
+	list := intListPattern
+	re := regexp.MustCompile(`^((` + list + `|\*)/)?(` + list + `|\*|H)/(` + list + `|\*|H)$`)
 	submatches := re.FindStringSubmatch(glob)
 
 	if submatches == nil {
 		return false
 	}
 
-	tg.year = parseIntList(submatches[2])
-	tg.month = parseIntList(submatches[3])
-	tg.day = parseIntList(submatches[4])
+	// H days are limited to 28, so they exist in every month.
+	month, monthOk := parseHashList(submatches[3], key, "month", 1, 12)
+	day, dayOk := parseHashList(submatches[4], key, "day", 1, 28)
+	year, yearOk := parseIntList(submatches[2])
+	if !monthOk || !dayOk || !yearOk {
+		return false
+	}
+
+	tg.year = year
+	tg.month = month
+	tg.day = day
 	return true
 }
 
-func (tg *TimeGlob) parseTime(glob string) bool {
-	re := regexp.MustCompile(`^([0-9,]+|\*):([0-9,]+|\*)(:([0-9,]+|\*))?$`)
+func (tg *TimeGlob) parseTime(glob, key string) bool {
+	list := intListPattern
+	re := regexp.MustCompile(`^(` + list + `|\*|H):(` + list + `|\*|H)(:(` + list + `|\*|H)(\.([0-9,]+|\*))?)?$`)
 	submatches := re.FindStringSubmatch(glob)
 
 	if submatches == nil {
 		return false
 	}
 
-	tg.hour = parseIntList(submatches[1])
-	tg.minute = parseIntList(submatches[2])
+	if submatches[6] != "" {
+		// If fractional seconds aren't explicitly set, retain the default value
+		// of '0'
+		millisecond, ok := parseMillisecondList(submatches[6])
+		if !ok {
+			return false
+		}
+		tg.millisecond = millisecond
+	}
+
+	hour, hourOk := parseHashList(submatches[1], key, "hour", 0, 23)
+	minute, minuteOk := parseHashList(submatches[2], key, "minute", 0, 59)
+	if !hourOk || !minuteOk {
+		return false
+	}
+
+	tg.hour = hour
+	tg.minute = minute
 	if submatches[4] != "" {
 		// If seconds aren't explicitly set, retain the default value of '0'
-		tg.second = parseIntList(submatches[4])
+		second, ok := parseHashList(submatches[4], key, "second", 0, 59)
+		if !ok {
+			return false
+		}
+		tg.second = second
 	}
 
 	return true
 }
 
-func (tg *TimeGlob) parseLocation(glob string) bool {
-	loc, err := time.LoadLocation(glob)
-	if glob != "" && err == nil {
-		tg.location = loc
-		return true
+func parseFixedZone(glob string) (*time.Location, bool) {
+	// Parse an ISO 8601 style UTC offset, such as "Z", "+05:30", "-0800" or
+	// "UTC-8", or a known timezone abbreviation such as "PST", into a timezone
+	// with a fixed offset.
+
+	if glob == "Z" {
+		return time.UTC, true
+	}
+
+	if hours, ok := zoneAbbreviations[glob]; ok {
+		return time.FixedZone(glob, int(hours*60*60)), true
+	}
+
+	re := regexp.MustCompile(`^(UTC|GMT)?([+-])([0-9]{1,2})(:?([0-9]{2}))?$`)
+	submatches := re.FindStringSubmatch(glob)
+
+	if submatches == nil {
+		return nil, false
+	}
+
+	hours, _ := strconv.Atoi(submatches[3])
+	minutes := 0
+	if submatches[5] != "" {
+		minutes, _ = strconv.Atoi(submatches[5])
+	}
+
+	// Without a colon, minutes require two digit hours. IE: "-0800", not "-800".
+	if len(submatches[3]) == 1 && submatches[4] != "" && submatches[4][0] != ':' {
+		return nil, false
+	}
+
+	if hours > 14 || minutes > 59 {
+		return nil, false
+	}
+
+	offset := (hours*60 + minutes) * 60
+	if submatches[2] == "-" {
+		offset = -offset
+	}
+
+	return time.FixedZone(glob, offset), true
+}
+
+func loadLocation(name string, loader LocationLoader) (*time.Location, error) {
+	// Look up a timezone by name with loader, or time.LoadLocation if it's
+	// nil, falling back to fixed offsets and abbreviations. Globs and the
+	// other formats all find timezones this way.
+
+	if loader == nil {
+		loader = time.LoadLocation
+	}
+
+	loc, err := loader(name)
+	if err != nil {
+		fixed, ok := parseFixedZone(name)
+		if !ok {
+			return nil, err
+		}
+		loc = fixed
+	}
+
+	return loc, nil
+}
+
+func namedLocation(loc *time.Location) bool {
+	// Can other systems look the timezone up by its name? Fixed offsets and
+	// abbreviations from parseFixedZone can't be.
+
+	if loc.String() == "" {
+		return false
+	}
+	_, err := time.LoadLocation(loc.String())
+	return err == nil
+}
+
+func (tg *TimeGlob) parseLocation(glob string, loader LocationLoader) bool {
+	if glob == "" {
+		return false
 	}
-	return false
+
+	loc, err := loadLocation(glob, loader)
+	if err != nil {
+		return false
+	}
+
+	tg.location = loc
+	return true
 }
@@ -1,6 +1,7 @@
 package timeglob
 
 import (
+	"fmt"
 	"gopkg.in/check.v1"
 	"time"
 )
@@ -18,6 +19,10 @@ func (suite *MySuite) TestParseGlobParseGood(c *check.C) {
 		"12/25",
 		"19:37",
 		"19:37:22",
+		"19:37:22.500",
+		"19:37:22.5",
+		"*/*/* *:*:*.* UTC",
+		"*/*/* *:*:*.0,250,500,750 UTC",
 		"*/*/* *:*:* UTC",
 		"*/*/* *:* UTC",
 		"*/*/* *:*",
@@ -27,6 +32,21 @@ func (suite *MySuite) TestParseGlobParseGood(c *check.C) {
 		"2015,/12/25,25 10,:37 America/New_York",
 		"2015/12/25 ,:37 America/New_York",
 		",2015/12/25 19:37 America/New_York",
+		"2015/12/25 19:37 Z",
+		"2015/12/25 19:37 +05:30",
+		"2015/12/25 19:37 -0800",
+		"2015/12/25 19:37 +8",
+		"2015/12/25 19:37 UTC-8",
+		"2015/12/25 19:37 GMT+01:00",
+		"2015/12/25 19:37 PST",
+		"2015/12/25 19:37 NST",
+		"Mon 19:37",
+		"Mon-Fri 19:37 UTC",
+		"mon,WED,Friday 12/25 19:37",
+		"Fri-Mon */1",
+		"Sat",
+		"12/24-26",
+		"2015-2017/1-3,12/1 9-17:0-30:15-15 UTC",
 	}
 
 	for _, g := range globs {
@@ -50,6 +70,28 @@ func (suite *MySuite) TestParseGlobParseBad(c *check.C) {
 		"2015/12/25 19:37 Extra America/New_York",
 		"2015/12/25 19:37 America/New_York Extra",
 		"2015/12/25 aa:37 America/New_York",
+		"19:37.500",
+		"19:37:22.",
+		"19:37:22.5000",
+		"19:37:22.500.1",
+		"2015/12/25 19:37 +25:00",
+		"2015/12/25 19:37 +05:60",
+		"2015/12/25 19:37 +800",
+		"2015/12/25 19:37 +5:3",
+		"2015/12/25 19:37 UTC+",
+		"2015/12/25 19:37 XYZ",
+		"Funday 19:37",
+		"Mon- 19:37",
+		"Mon-Tue-Wed 19:37",
+		"Mon Tue 19:37",
+		"12/25 Mon 19:37",
+		"12/26-24",
+		"12/24- 19:37",
+		"12/-24 19:37",
+		"12/24--26 19:37",
+		"17-9:00",
+		"1-99999/1/1",
+		"19:37:22.1-5",
 	}
 
 	for _, g := range globs {
@@ -68,39 +110,39 @@ func matchesExpected(c *check.C, glob string, expected *TimeGlob) {
 
 func (suite *MySuite) TestParseGlobParseVerify(c *check.C) {
 	matchesExpected(c, "2015/12/25 19:37:22 UTC", &TimeGlob{
-		[]int{2015}, []int{12}, []int{25},
-		[]int{19}, []int{37}, []int{22},
-		time.UTC,
+		[]int{2015}, []int{12}, []int{25}, nil,
+		[]int{19}, []int{37}, []int{22}, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, "2015/12/25 UTC", &TimeGlob{
-		[]int{2015}, []int{12}, []int{25},
-		intRange(0, 0), intRange(0, 0), []int{0},
-		time.UTC,
+		[]int{2015}, []int{12}, []int{25}, nil,
+		intRange(0, 0), intRange(0, 0), []int{0}, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, "12/25 UTC", &TimeGlob{
-		nil, []int{12}, []int{25},
-		intRange(0, 0), intRange(0, 0), []int{0},
-		time.UTC,
+		nil, []int{12}, []int{25}, nil,
+		intRange(0, 0), intRange(0, 0), []int{0}, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, "19:37:22 UTC", &TimeGlob{
-		nil, nil, nil,
-		[]int{19}, []int{37}, []int{22},
-		time.UTC,
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, []int{22}, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, "19:37:* UTC", &TimeGlob{
-		nil, nil, nil,
-		[]int{19}, []int{37}, nil,
-		time.UTC,
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, nil, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, "19:37 UTC", &TimeGlob{
-		nil, nil, nil,
-		[]int{19}, []int{37}, []int{0},
-		time.UTC,
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, []int{0}, []int{0},
+		time.UTC, nil,
 	})
 
 	// matchesExpected(c, "2015/12/25 19:37", &TimeGlob{
@@ -110,21 +152,103 @@ func (suite *MySuite) TestParseGlobParseVerify(c *check.C) {
 	// })
 
 	matchesExpected(c, "2015,2016/11,12/22,25 8,19:22,37 UTC", &TimeGlob{
-		[]int{2015, 2016}, []int{11, 12}, []int{22, 25},
-		[]int{8, 19}, []int{22, 37}, []int{0},
-		time.UTC,
+		[]int{2015, 2016}, []int{11, 12}, []int{22, 25}, nil,
+		[]int{8, 19}, []int{22, 37}, []int{0}, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, "2015,2016,/11,11,12/25,22,25 19,8:22,37:11,22 UTC", &TimeGlob{
-		[]int{2015, 2016}, []int{11, 12}, []int{22, 25},
-		[]int{8, 19}, []int{22, 37}, []int{11, 22},
-		time.UTC,
+		[]int{2015, 2016}, []int{11, 12}, []int{22, 25}, nil,
+		[]int{8, 19}, []int{22, 37}, []int{11, 22}, []int{0},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "12:00:00.500 UTC", &TimeGlob{
+		nil, nil, nil, nil,
+		[]int{12}, []int{0}, []int{0}, []int{500},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "*:*:*.0,25,5,250 UTC", &TimeGlob{
+		nil, nil, nil, nil,
+		nil, nil, nil, []int{0, 250, 500},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "*:*:*.* UTC", &TimeGlob{
+		nil, nil, nil, nil,
+		nil, nil, nil, nil,
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "19:37 Z", &TimeGlob{
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, []int{0}, []int{0},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "19:37 +05:30", &TimeGlob{
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, []int{0}, []int{0},
+		time.FixedZone("+05:30", (5*60+30)*60), nil,
+	})
+
+	matchesExpected(c, "19:37 UTC-8", &TimeGlob{
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, []int{0}, []int{0},
+		time.FixedZone("UTC-8", -8*60*60), nil,
+	})
+
+	matchesExpected(c, "19:37 PDT", &TimeGlob{
+		nil, nil, nil, nil,
+		[]int{19}, []int{37}, []int{0}, []int{0},
+		time.FixedZone("PDT", -7*60*60), nil,
+	})
+
+	// Abbreviations never shadow IANA timezone names, so they mean the same
+	// thing on every host.
+	for name := range zoneAbbreviations {
+		if name == "UTC" || name == "GMT" {
+			continue
+		}
+		_, err := time.LoadLocation(name)
+		c.Check(err, check.NotNil, check.Commentf(name))
+	}
+
+	matchesExpected(c, "Mon-Fri 9:00 UTC", &TimeGlob{
+		nil, nil, nil, []int{1, 2, 3, 4, 5},
+		[]int{9}, []int{0}, []int{0}, []int{0},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "Fri-Mon,wed 12/25 UTC", &TimeGlob{
+		nil, []int{12}, []int{25}, []int{0, 1, 3, 5, 6},
+		[]int{0}, []int{0}, []int{0}, []int{0},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "Sun-Sat 9:00 UTC", &TimeGlob{
+		nil, nil, nil, nil,
+		[]int{9}, []int{0}, []int{0}, []int{0},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "12/24-26 9-11,17:0 UTC", &TimeGlob{
+		nil, []int{12}, []int{24, 25, 26}, nil,
+		[]int{9, 10, 11, 17}, []int{0}, []int{0}, []int{0},
+		time.UTC, nil,
+	})
+
+	matchesExpected(c, "2015-2017/12-12/1-2,2 0:00 UTC", &TimeGlob{
+		[]int{2015, 2016, 2017}, []int{12}, []int{1, 2}, nil,
+		[]int{0}, []int{0}, []int{0}, []int{0},
+		time.UTC, nil,
 	})
 
 	matchesExpected(c, ",/,/, ,:,:, UTC", &TimeGlob{
-		[]int{}, []int{}, []int{},
-		[]int{}, []int{}, []int{},
-		time.UTC,
+		[]int{}, []int{}, []int{}, nil,
+		[]int{}, []int{}, []int{}, []int{0},
+		time.UTC, nil,
 	})
 
 }
@@ -165,3 +289,44 @@ func (suite *MySuite) TestParseGlobParseWildcardEquivalence(c *check.C) {
 	testEquivalence(full)
 	testEquivalence(time)
 }
+
+func (suite *MySuite) TestParseHash(c *check.C) {
+	parse := func(glob, key string) *TimeGlob {
+		tg, err := ParseWithOptions(glob, ParseOptions{Key: key})
+		c.Assert(err, check.IsNil)
+		return tg
+	}
+
+	// The same key always gives the same values.
+	a := parse("H:H:H UTC", "backup")
+	c.Check(parse("H:H:H UTC", "backup"), check.DeepEquals, a)
+	c.Check(len(a.hour), check.Equals, 1)
+	c.Check(len(a.minute), check.Equals, 1)
+	c.Check(len(a.second), check.Equals, 1)
+
+	// Different keys are spread across the range.
+	minutes := map[int]bool{}
+	for i := 0; i < 100; i++ {
+		tg := parse("*:H UTC", fmt.Sprintf("job%d", i))
+		c.Check(tg.hour, check.IsNil)
+		c.Assert(len(tg.minute), check.Equals, 1)
+		c.Check(tg.minute[0] >= 0 && tg.minute[0] < 60, check.Equals, true)
+		minutes[tg.minute[0]] = true
+	}
+	c.Check(len(minutes) > 30, check.Equals, true)
+
+	// Days are limited to ones which exist in every month.
+	for i := 0; i < 100; i++ {
+		tg := parse("H/H 3:00", fmt.Sprintf("job%d", i))
+		c.Check(tg.month[0] >= 1 && tg.month[0] <= 12, check.Equals, true)
+		c.Check(tg.day[0] >= 1 && tg.day[0] <= 28, check.Equals, true)
+	}
+
+	// H needs a key, and isn't allowed for years or fractions.
+	for _, g := range []string{"H:0", "*/*/H", "H/1/1", "0:0:0.H"} {
+		_, err := ParseWithOptions(g, ParseOptions{Key: "job"})
+		c.Check(err == nil, check.Equals, g == "H:0" || g == "*/*/H", check.Commentf(g))
+		_, err = Parse(g)
+		c.Check(err, check.NotNil, check.Commentf(g))
+	}
+}
@@ -16,13 +16,13 @@ func (tg *TimeGlob) Prev(now time.Time) time.Time {
 	return result
 }
 
-func (tg *TimeGlob) expandPrev(now time.Time) (years, months, days, hours, minutes, seconds []int) {
+func (tg *TimeGlob) expandPrev(now time.Time) (years, months, days, hours, minutes, seconds, milliseconds []int) {
 	// Expand wildcard values out to explict lists of values.
 
 	years = reverseCopy(tg.year)
 	if len(years) == 0 {
 		// Expand years wildcard in a limited way to avoid searching forever.
-		years = intRange(now.Year(), now.Year()-YEAR_SEARCH_DEPTH)
+		years = intRange(now.Year(), now.Year()-tg.yearSearchDepth())
 	}
 
 	months = reverseCopy(tg.month)
@@ -31,7 +31,7 @@ func (tg *TimeGlob) expandPrev(now time.Time) (years, months, days, hours, minut
 	}
 
 	days = reverseCopy(tg.day)
-	if len(days) == 0 {
+	if len(days) == 0 || !tg.listsDays() {
 		days = intRange(32, 1)
 	}
 
@@ -50,11 +50,16 @@ func (tg *TimeGlob) expandPrev(now time.Time) (years, months, days, hours, minut
 		seconds = intRange(61, 0)
 	}
 
-	return years, months, days, hours, minutes, seconds
+	milliseconds = reverseCopy(tg.millisecond)
+	if len(milliseconds) == 0 {
+		milliseconds = intRange(999, 0)
+	}
+
+	return years, months, days, hours, minutes, seconds, milliseconds
 }
 
 func (tg *TimeGlob) prevDate(now time.Time) time.Time {
-	years, months, days, hours, minutes, seconds := tg.expandPrev(now)
+	years, months, days, hours, minutes, seconds, milliseconds := tg.expandPrev(now)
 
 	dateNow := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, tg.location)
 
@@ -65,7 +70,7 @@ func (tg *TimeGlob) prevDate(now time.Time) time.Time {
 				// For performance validate that each date might be parse of a valid
 				// result before searching inside the date.
 				searchDate := tg.dateNoNormalize(year, month, day, 0, 0, 0)
-				if searchDate == UNKNOWN || dateNow.Before(searchDate) {
+				if searchDate == UNKNOWN || dateNow.Before(searchDate) || !tg.matchesDay(year, month, day) {
 					continue
 				}
 
@@ -80,23 +85,43 @@ func (tg *TimeGlob) prevDate(now time.Time) time.Time {
 						base := tg.dateNoNormalize(year, month, day, hour, 0, 0)
 						advanced := base.Add(time.Hour)
 
-						if base.Hour() == advanced.Hour() {
+						if base.Hour() == advanced.Hour() && !spanAfter(advanced, now) {
 							for _, minute := range minutes {
 								for _, second := range seconds {
-									result := tg.adjustMinutesSeconds(advanced, minute, second)
-									if result != UNKNOWN && (now.Equal(result) || now.After(result)) {
-										return result
+									adjusted := tg.adjustMinutesSeconds(advanced, minute, second)
+									if adjusted == UNKNOWN || spanAfter(adjusted, now) {
+										continue
+									}
+									for _, millisecond := range milliseconds {
+										result := addMilliseconds(adjusted, millisecond)
+										if result != UNKNOWN && (now.Equal(result) || now.After(result)) {
+											return result
+										}
 									}
 								}
 							}
 						}
 					}
 
+					// Hours, minutes and seconds which start after now are skipped
+					// without stepping through them, so wildcards stay fast.
+					if spanAfter(tg.dateNoNormalize(year, month, day, hour, 0, 0), now) {
+						continue
+					}
 					for _, minute := range minutes {
+						if spanAfter(tg.dateNoNormalize(year, month, day, hour, minute, 0), now) {
+							continue
+						}
 						for _, second := range seconds {
-							result := tg.dateNoNormalize(year, month, day, hour, minute, second)
-							if result != UNKNOWN && (now.Equal(result) || now.After(result)) {
-								return result
+							base := tg.dateNoNormalize(year, month, day, hour, minute, second)
+							if base == UNKNOWN || spanAfter(base, now) {
+								continue
+							}
+							for _, millisecond := range milliseconds {
+								result := addMilliseconds(base, millisecond)
+								if result != UNKNOWN && (now.Equal(result) || now.After(result)) {
+									return result
+								}
 							}
 						}
 					}
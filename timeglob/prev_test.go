@@ -329,6 +329,25 @@ func (suite *MySuite) TestPrevComma(c *check.C) {
 		})
 }
 
+func (suite *MySuite) TestPrevMillisecond(c *check.C) {
+	tg, err := Parse("*:*:0.0,250,500,750 UTC")
+	c.Assert(err, check.IsNil)
+
+	validatePrevSequence(c, tg,
+		tg.dateNoNormalize(2017, 1, 1, 0, 0, 1),
+		[]time.Time{
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(750 * time.Millisecond),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(500 * time.Millisecond),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(250 * time.Millisecond),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0),
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 0).Add(750 * time.Millisecond),
+		})
+
+	// Exact match.
+	now := tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(250 * time.Millisecond)
+	validatePrev(c, tg, now, now)
+}
+
 func (suite *MySuite) TestPrevPerformance(c *check.C) {
 	// Demonstrate performance issues.
 
@@ -343,4 +362,16 @@ func (suite *MySuite) TestPrevPerformance(c *check.C) {
 			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0),
 			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59),
 		})
+
+	// This used to step through every millisecond until midnight.
+	tg, err = Parse("*:*:*.* UTC")
+	c.Assert(err, check.IsNil)
+
+	validatePrevSequence(c, tg,
+		tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(1500*time.Microsecond),
+		[]time.Time{
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0).Add(time.Millisecond),
+			tg.dateNoNormalize(2017, 1, 1, 0, 0, 0),
+			tg.dateNoNormalize(2016, 12, 31, 23, 59, 59).Add(999 * time.Millisecond),
+		})
 }
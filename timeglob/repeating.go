@@ -0,0 +1,281 @@
+package timeglob
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An ISO 8601 duration, such as P1Y2M3DT4H5M6.5S. Years, months and days are
+// added on the calendar, so P1D is the same wall clock time the next day, even
+// across a daylight saving change. The rest is a fixed duration.
+type Period struct {
+	Years, Months, Days int
+	Duration            time.Duration
+}
+
+// A schedule which starts at a given time, and repeats after each period,
+// either forever or a fixed number of times. Unlike a TimeGlob, it can
+// represent intervals like every 90 minutes, or every other week.
+type RepeatingInterval struct {
+	start  time.Time
+	period Period
+
+	// The number of matches, including start, or -1 to repeat forever.
+	count int
+}
+
+var isoPeriodRegexp = regexp.MustCompile(
+	`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+// The layouts accepted for the start of an ISO 8601 repeating interval, with
+// and without a UTC offset.
+var isoStartLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"20060102T150405Z07:00",
+}
+
+var isoLocalLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"20060102T150405",
+}
+
+func NewRepeatingInterval(start time.Time, period Period, count int) (*RepeatingInterval, error) {
+	// Create a schedule which matches start, and each period after it, count
+	// times in total. A negative count repeats forever.
+
+	if count < 0 {
+		count = -1
+	}
+
+	if period.Years < 0 || period.Months < 0 || period.Days < 0 || period.Duration < 0 {
+		return nil, fmt.Errorf("Period can't be negative: %s", period)
+	}
+	if period.Years == 0 && period.Months == 0 && period.Days == 0 && period.Duration == 0 {
+		return nil, fmt.Errorf("Period can't be empty")
+	}
+
+	return &RepeatingInterval{start, period, count}, nil
+}
+
+func ParseRepeatingInterval(text string) (*RepeatingInterval, error) {
+	// Parse an ISO 8601 repeating interval of the form R[n]/start/period,
+	// such as "R5/2025-01-01T00:00:00Z/PT6H" or "R/2025-01-01T09:00/P1W".
+	// R5 matches 5 times, starting with start, and R without a number repeats
+	// forever. A start without a UTC offset is in Local.
+
+	parts := strings.Split(text, "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "R") {
+		return nil, fmt.Errorf("Not a valid repeating interval: %s", text)
+	}
+
+	count := -1
+	if parts[0] != "R" {
+		var err error
+		count, err = strconv.Atoi(parts[0][1:])
+		if err != nil || count < -1 {
+			return nil, fmt.Errorf("Not a valid repeating interval: %s (bad repetitions)", text)
+		}
+	}
+
+	start, err := parseISOTime(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Not a valid repeating interval: %s (%s)", text, err)
+	}
+
+	period, err := ParsePeriod(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Not a valid repeating interval: %s (%s)", text, err)
+	}
+
+	return NewRepeatingInterval(start, period, count)
+}
+
+func parseISOTime(text string) (time.Time, error) {
+	for _, layout := range isoStartLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			// time.Parse uses Local when the offset happens to match it, which
+			// would lose the offset in String.
+			if t.Location() == time.Local {
+				_, offset := t.Zone()
+				t = t.In(time.FixedZone("", offset))
+			}
+			return t, nil
+		}
+	}
+	for _, layout := range isoLocalLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad start %s", text)
+}
+
+func ParsePeriod(text string) (Period, error) {
+	// Parse an ISO 8601 duration, such as P1DT12H, or P2W. Only the seconds
+	// may have a fraction.
+
+	m := isoPeriodRegexp.FindStringSubmatch(text)
+	if m == nil || text == "P" || strings.HasSuffix(text, "T") {
+		return Period{}, fmt.Errorf("bad period %s", text)
+	}
+
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	period := Period{
+		Years:  number(m[1]),
+		Months: number(m[2]),
+		Days:   number(m[3])*7 + number(m[4]),
+		Duration: time.Duration(number(m[5]))*time.Hour +
+			time.Duration(number(m[6]))*time.Minute,
+	}
+
+	if m[7] != "" {
+		seconds, _ := strconv.ParseFloat(strings.Replace(m[7], ",", ".", 1), 64)
+		period.Duration += time.Duration(seconds * float64(time.Second))
+	}
+
+	return period, nil
+}
+
+func (p Period) String() string {
+	// Write the period in ISO 8601 form, such as P1DT12H.
+
+	text := "P"
+	if p.Years != 0 {
+		text += fmt.Sprintf("%dY", p.Years)
+	}
+	if p.Months != 0 {
+		text += fmt.Sprintf("%dM", p.Months)
+	}
+	if p.Days != 0 {
+		if p.Days%7 == 0 && p.Years == 0 && p.Months == 0 && p.Duration == 0 {
+			return text + fmt.Sprintf("%dW", p.Days/7)
+		}
+		text += fmt.Sprintf("%dD", p.Days)
+	}
+
+	if d := p.Duration; d != 0 || text == "P" {
+		text += "T"
+		if h := d / time.Hour; h != 0 {
+			text += fmt.Sprintf("%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m != 0 {
+			text += fmt.Sprintf("%dM", m)
+			d -= m * time.Minute
+		}
+		if d != 0 || text == "PT" {
+			text += strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+		}
+	}
+
+	return text
+}
+
+func (p Period) approximate() time.Duration {
+	// The average length of the period, used to estimate how many periods fit
+	// in a span of time.
+
+	const day = 24 * time.Hour
+	return time.Duration(p.Years)*(365*day+day/4) +
+		time.Duration(p.Months)*(30*day+day/2) +
+		time.Duration(p.Days)*day +
+		p.Duration
+}
+
+func (r *RepeatingInterval) String() string {
+	// Write the schedule in ISO 8601 form, which ParseRepeatingInterval would
+	// convert back into an equivalent schedule.
+
+	repeat := "R"
+	if r.count >= 0 {
+		repeat += strconv.Itoa(r.count)
+	}
+
+	var start string
+	if r.start.Location() == time.Local {
+		start = r.start.Format("2006-01-02T15:04:05.999999999")
+	} else {
+		start = r.start.Format(time.RFC3339Nano)
+	}
+
+	return repeat + "/" + start + "/" + r.period.String()
+}
+
+func (r *RepeatingInterval) Start() time.Time {
+	return r.start
+}
+
+func (r *RepeatingInterval) Period() Period {
+	return r.period
+}
+
+func (r *RepeatingInterval) Count() int {
+	// The number of matches, or -1 if the schedule repeats forever.
+
+	return r.count
+}
+
+func (r *RepeatingInterval) at(k int) time.Time {
+	// The k-th match, counting start as 0.
+
+	p := r.period
+	return r.start.AddDate(k*p.Years, k*p.Months, k*p.Days).Add(time.Duration(k) * p.Duration)
+}
+
+func (r *RepeatingInterval) index(now time.Time) int {
+	// The index of the last match at, or before now, which may be -1, or past
+	// the last match.
+
+	k := int(now.Sub(r.start) / r.period.approximate())
+	for k >= 0 && r.at(k).After(now) {
+		k--
+	}
+	for !r.at(k + 1).After(now) {
+		k++
+	}
+	if k < -1 {
+		k = -1
+	}
+	return k
+}
+
+func (r *RepeatingInterval) Next(now time.Time) time.Time {
+	// Find the first match after now. Returns UNKNOWN if there isn't one. The
+	// result is in the same timezone as now.
+
+	k := r.index(now) + 1
+	if r.count >= 0 && k >= r.count {
+		return UNKNOWN
+	}
+	return r.at(k).In(now.Location())
+}
+
+func (r *RepeatingInterval) Prev(now time.Time) time.Time {
+	// Find the last match before, or equal to now. Returns UNKNOWN if there
+	// isn't one. The result is in the same timezone as now.
+
+	k := r.index(now)
+	if r.count >= 0 && k >= r.count {
+		k = r.count - 1
+	}
+	if k < 0 {
+		return UNKNOWN
+	}
+	return r.at(k).In(now.Location())
+}
+
+func (r *RepeatingInterval) Matches(t time.Time) bool {
+	// Is t exactly one of the matches?
+
+	return t != UNKNOWN && r.Prev(t).Equal(t)
+}
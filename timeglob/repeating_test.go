@@ -0,0 +1,141 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestParsePeriod(c *check.C) {
+	periods := map[string]Period{
+		"PT6H":           {Duration: 6 * time.Hour},
+		"P1W":            {Days: 7},
+		"P1Y2M3D":        {1, 2, 3, 0},
+		"P1DT12H":        {Days: 1, Duration: 12 * time.Hour},
+		"PT1H30M":        {Duration: 90 * time.Minute},
+		"PT0.5S":         {Duration: 500 * time.Millisecond},
+		"PT1,25S":        {Duration: 1250 * time.Millisecond},
+		"P1Y2M3DT4H5M6S": {1, 2, 3, 4*time.Hour + 5*time.Minute + 6*time.Second},
+	}
+
+	for text, expected := range periods {
+		period, err := ParsePeriod(text)
+		c.Check(err, check.IsNil, check.Commentf(text))
+		c.Check(period, check.Equals, expected, check.Commentf(text))
+	}
+
+	for _, text := range []string{"", "P", "PT", "1D", "P1H", "PT1D", "P1.5D", "P-1D", "PT1S2M"} {
+		_, err := ParsePeriod(text)
+		c.Check(err, check.ErrorMatches, "bad period .*", check.Commentf(text))
+	}
+
+	// And back again.
+	for _, text := range []string{"PT6H", "P1W", "P1Y2M3D", "P1DT12H", "PT1H30M", "PT0.5S", "P14DT1S", "PT0S"} {
+		period, err := ParsePeriod(text)
+		c.Assert(err, check.IsNil)
+		c.Check(period.String(), check.Equals, text)
+	}
+}
+
+func (suite *MySuite) TestParseRepeatingInterval(c *check.C) {
+	r, err := ParseRepeatingInterval("R5/2025-01-01T00:00:00Z/PT6H")
+	c.Assert(err, check.IsNil)
+	c.Check(r.Start(), check.Equals, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Check(r.Period(), check.Equals, Period{Duration: 6 * time.Hour})
+	c.Check(r.Count(), check.Equals, 5)
+	c.Check(r.String(), check.Equals, "R5/2025-01-01T00:00:00Z/PT6H")
+
+	r, err = ParseRepeatingInterval("R/2025-01-01T09:00/P1W")
+	c.Assert(err, check.IsNil)
+	c.Check(r.Start(), check.Equals, time.Date(2025, 1, 1, 9, 0, 0, 0, time.Local))
+	c.Check(r.Count(), check.Equals, -1)
+	c.Check(r.String(), check.Equals, "R/2025-01-01T09:00:00/P1W")
+
+	r, err = ParseRepeatingInterval("R/2025-01-01T09:00:00-05:00/P1D")
+	c.Assert(err, check.IsNil)
+	_, offset := r.Start().Zone()
+	c.Check(offset, check.Equals, -5*3600)
+	c.Check(r.String(), check.Equals, "R/2025-01-01T09:00:00-05:00/P1D")
+
+	bad := []string{
+		"",
+		"2025-01-01T00:00:00Z/PT6H",
+		"R5/2025-01-01T00:00:00Z",
+		"Rx/2025-01-01T00:00:00Z/PT6H",
+		"R5/yesterday/PT6H",
+		"R5/2025-01-01T00:00:00Z/6H",
+		"R5/PT6H/2025-01-01T00:00:00Z",
+	}
+	for _, text := range bad {
+		_, err := ParseRepeatingInterval(text)
+		c.Check(err, check.ErrorMatches, "Not a valid repeating interval.*", check.Commentf(text))
+	}
+
+	_, err = ParseRepeatingInterval("R/2025-01-01T00:00:00Z/PT0S")
+	c.Check(err, check.ErrorMatches, "Period can't be empty")
+}
+
+func (suite *MySuite) TestRepeatingIntervalNextPrev(c *check.C) {
+	r, err := ParseRepeatingInterval("R5/2025-01-01T00:00:00Z/PT6H")
+	c.Assert(err, check.IsNil)
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	hours := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+
+	c.Check(r.Next(hours(-100)), check.Equals, hours(0))
+	c.Check(r.Next(hours(0)), check.Equals, hours(6))
+	c.Check(r.Next(hours(7)), check.Equals, hours(12))
+	c.Check(r.Next(hours(23)), check.Equals, hours(24))
+	c.Check(r.Next(hours(24)), check.Equals, UNKNOWN)
+
+	c.Check(r.Prev(hours(-1)), check.Equals, UNKNOWN)
+	c.Check(r.Prev(hours(0)), check.Equals, hours(0))
+	c.Check(r.Prev(hours(7)), check.Equals, hours(6))
+	c.Check(r.Prev(hours(1000)), check.Equals, hours(24))
+
+	c.Check(r.Matches(hours(18)), check.Equals, true)
+	c.Check(r.Matches(hours(18).Add(time.Nanosecond)), check.Equals, false)
+	c.Check(r.Matches(hours(30)), check.Equals, false)
+	c.Check(r.Matches(hours(-6)), check.Equals, false)
+
+	// Results are in the timezone of now.
+	ny, err := time.LoadLocation("America/New_York")
+	c.Assert(err, check.IsNil)
+	c.Check(r.Next(hours(1).In(ny)).Location(), check.Equals, ny)
+
+	// R0 never matches.
+	r, err = ParseRepeatingInterval("R0/2025-01-01T00:00:00Z/PT6H")
+	c.Assert(err, check.IsNil)
+	c.Check(r.Next(hours(-1)), check.Equals, UNKNOWN)
+	c.Check(r.Prev(hours(1)), check.Equals, UNKNOWN)
+}
+
+func (suite *MySuite) TestRepeatingIntervalCalendar(c *check.C) {
+	ny, err := time.LoadLocation("America/New_York")
+	c.Assert(err, check.IsNil)
+
+	// Days keep the same wall clock time across daylight saving changes.
+	start := time.Date(2016, 3, 1, 9, 0, 0, 0, ny)
+	r, err := NewRepeatingInterval(start, Period{Days: 7}, -1)
+	c.Assert(err, check.IsNil)
+
+	now := time.Date(2016, 3, 14, 0, 0, 0, 0, ny)
+	c.Check(r.Next(now), check.Equals, time.Date(2016, 3, 15, 9, 0, 0, 0, ny))
+	c.Check(r.Prev(now), check.Equals, time.Date(2016, 3, 8, 9, 0, 0, 0, ny))
+
+	// Fixed durations don't.
+	r, err = NewRepeatingInterval(start, Period{Duration: 7 * 24 * time.Hour}, -1)
+	c.Assert(err, check.IsNil)
+	c.Check(r.Next(now), check.Equals, time.Date(2016, 3, 15, 10, 0, 0, 0, ny))
+
+	// Months and years, far from the start.
+	r, err = ParseRepeatingInterval("R/2000-01-15T12:00:00Z/P1Y1M")
+	c.Assert(err, check.IsNil)
+	now = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := r.Next(now)
+	c.Check(next.After(now), check.Equals, true)
+	c.Check(r.Matches(next), check.Equals, true)
+	c.Check(r.Prev(next.Add(-time.Nanosecond)).AddDate(1, 1, 0), check.Equals, next)
+
+	_, err = NewRepeatingInterval(start, Period{Days: -1}, -1)
+	c.Check(err, check.ErrorMatches, "Period can't be negative: .*")
+}
@@ -0,0 +1,538 @@
+package timeglob
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The RRULE frequencies, from coarsest to finest, in the same order as the
+// TimeGlob fields they step through.
+var rruleFrequencies = []string{"YEARLY", "MONTHLY", "DAILY", "HOURLY", "MINUTELY", "SECONDLY"}
+
+// The RRULE parts which list values for each TimeGlob field, in the same
+// order, with their ranges.
+var rruleFields = []struct {
+	part     string
+	min, max int
+}{
+	{"", 0, 0},
+	{"BYMONTH", 1, 12},
+	{"BYMONTHDAY", 1, 31},
+	{"BYHOUR", 0, 23},
+	{"BYMINUTE", 0, 59},
+	{"BYSECOND", 0, 59},
+}
+
+// The RRULE weekdays, in the same order as time.Weekday.
+var rruleWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+const (
+	RRULE_YEAR = iota
+	RRULE_MONTH
+	RRULE_DAY
+	RRULE_HOUR
+	RRULE_MINUTE
+	RRULE_SECOND
+)
+
+func ParseRRule(text string) (*TimeGlob, error) {
+	return ParseRRuleWithOptions(text, ParseOptions{})
+}
+
+func ParseRRuleWithOptions(text string, options ParseOptions) (*TimeGlob, error) {
+	// Convert an iCalendar recurrence into a TimeGlob. text holds DTSTART and
+	// RRULE content lines, such as:
+	//
+	//   DTSTART;TZID=America/New_York:19970902T090000
+	//   RRULE:FREQ=DAILY;BYHOUR=9,17
+	//
+	// The timezone comes from DTSTART, which also supplies any values the rule
+	// doesn't, as RFC 5545 describes. TZIDs are found with
+	// options.LocationLoader, or as fixed offsets, in the same way as globs.
+	// TimeGlobs have no start, so the result also matches times before
+	// DTSTART.
+	//
+	// BYDAY lists weekdays, such as "MO,WE", and MONTHLY or YEARLY rules with
+	// BYMONTH may number them, such as "1FR" or "-2MO". Rules which end
+	// (COUNT, UNTIL), step weekly or daily by an INTERVAL, or step by an
+	// INTERVAL which doesn't evenly divide the next larger unit can't be
+	// represented, and return an error.
+
+	var dtstart time.Time
+	rule := ""
+
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		colon := icalValueIndex(line)
+		if colon < 0 {
+			return nil, fmt.Errorf("Not a valid iCalendar line: %s", line)
+		}
+		params := strings.Split(line[:colon], ";")
+		value := line[colon+1:]
+
+		switch strings.ToUpper(params[0]) {
+		case "DTSTART":
+			var err error
+			dtstart, err = parseICalTime(value, params[1:], options.LocationLoader)
+			if err != nil {
+				return nil, err
+			}
+		case "RRULE":
+			rule = value
+		default:
+			return nil, fmt.Errorf("RRULE can't be represented by a TimeGlob: %s (unsupported property)", params[0])
+		}
+	}
+
+	if dtstart.IsZero() || rule == "" {
+		return nil, fmt.Errorf("Not a valid recurrence, DTSTART and RRULE are required: %s", text)
+	}
+
+	unsupported := func(part, reason string) error {
+		return fmt.Errorf("RRULE can't be represented by a TimeGlob: %s (%s)", part, reason)
+	}
+
+	freq := -1
+	weekly := false
+	byDay := false
+	var weekdays []int
+	var nth []nthWeekday
+	interval := 1
+	lists := make([][]int, len(rruleFields))
+
+	for _, part := range strings.Split(rule, ";") {
+		eq := strings.Index(part, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("Not a valid RRULE: %s", rule)
+		}
+		key, value := strings.ToUpper(part[:eq]), strings.ToUpper(part[eq+1:])
+
+		switch key {
+		case "FREQ":
+			if value == "WEEKLY" {
+				weekly = true
+				value = "DAILY"
+			}
+			for i, f := range rruleFrequencies {
+				if f == value {
+					freq = i
+				}
+			}
+			if freq < 0 {
+				return nil, fmt.Errorf("Not a valid RRULE: %s (bad FREQ)", rule)
+			}
+
+		case "INTERVAL":
+			var err error
+			interval, err = strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("Not a valid RRULE: %s (bad INTERVAL)", rule)
+			}
+
+		case "COUNT", "UNTIL":
+			return nil, unsupported(part, "TimeGlobs have no end")
+
+		case "BYDAY":
+			var err error
+			weekdays, nth, err = parseRRuleWeekdays(value)
+			if err != nil {
+				return nil, fmt.Errorf("Not a valid RRULE: %s (%s)", rule, err)
+			}
+			byDay = true
+
+		case "BYYEARDAY", "BYWEEKNO", "BYSETPOS":
+			return nil, unsupported(part, "not supported")
+
+		case "WKST":
+			// Only matters for weekly rules with an interval.
+
+		default:
+			found := false
+			for i, field := range rruleFields {
+				if field.part == key && key != "" {
+					values, err := parseRRuleList(value, field.min, field.max, i == RRULE_DAY)
+					if err != nil {
+						return nil, unsupported(part, err.Error())
+					}
+					lists[i] = values
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("Not a valid RRULE: %s (unknown part %s)", rule, key)
+			}
+		}
+	}
+
+	if freq < 0 {
+		return nil, fmt.Errorf("Not a valid RRULE: %s (FREQ is required)", rule)
+	}
+
+	if weekly && interval != 1 {
+		return nil, unsupported("INTERVAL", "TimeGlobs can't skip weeks")
+	}
+	if weekly && !byDay {
+		// Weekly rules repeat on the weekday of DTSTART.
+		weekdays = []int{int(dtstart.Weekday())}
+	}
+
+	// Numbered weekdays count within the month for MONTHLY rules, and YEARLY
+	// rules with BYMONTH, but within the year for other YEARLY rules.
+	if nth != nil {
+		switch {
+		case weekly || (freq != RRULE_YEAR && freq != RRULE_MONTH):
+			return nil, fmt.Errorf("Not a valid RRULE: %s (numbered BYDAY values need FREQ=MONTHLY or YEARLY)", rule)
+		case freq == RRULE_YEAR && lists[RRULE_MONTH] == nil:
+			return nil, unsupported("BYDAY", "TimeGlobs can't number weekdays of the year")
+		}
+		for _, n := range nth {
+			if n.n > 5 || n.n < -5 {
+				return nil, unsupported("BYDAY", "a month has at most 5 of each weekday")
+			}
+		}
+	}
+
+	// The DTSTART value for each field.
+	starts := []int{
+		dtstart.Year(), int(dtstart.Month()), dtstart.Day(),
+		dtstart.Hour(), dtstart.Minute(), dtstart.Second(),
+	}
+
+	values := make([][]int, len(rruleFields))
+	for i := range rruleFields {
+		switch {
+		case i == freq:
+			values[i] = lists[i]
+			if interval != 1 {
+				stepped, err := rruleInterval(i, starts[i], interval)
+				if err != nil {
+					return nil, unsupported(fmt.Sprintf("INTERVAL=%d", interval), err.Error())
+				}
+				values[i] = intersect(stepped, lists[i])
+			}
+
+		case lists[i] != nil || i < freq:
+			values[i] = lists[i]
+
+		case i == RRULE_DAY && byDay:
+			// BYDAY picks the days.
+
+		case i == RRULE_MONTH && (lists[RRULE_DAY] != nil || byDay):
+			// Yearly rules with BYMONTHDAY or BYDAY expand to every month.
+
+		default:
+			values[i] = []int{starts[i]}
+		}
+	}
+
+	result := new()
+	result.year = values[RRULE_YEAR]
+	result.month = values[RRULE_MONTH]
+	result.day = values[RRULE_DAY]
+	result.hour = values[RRULE_HOUR]
+	result.minute = values[RRULE_MINUTE]
+	result.second = values[RRULE_SECOND]
+	result.weekday = weekdays
+	if nth != nil {
+		result.rules = &dayRules{nth: nth}
+	}
+	result.location = dtstart.Location()
+	return &result, nil
+}
+
+func icalValueIndex(line string) int {
+	// The index of the colon which starts the value of a content line, or -1.
+	// Quoted parameter values, like TZID="+05:30", may contain colons.
+
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ':' && !quoted:
+			return i
+		}
+	}
+	return -1
+}
+
+func parseICalTime(value string, params []string, loader LocationLoader) (time.Time, error) {
+	// Parse an iCalendar DATE or DATE-TIME value, in the timezone from a TZID
+	// parameter, in UTC if it ends with Z, or otherwise in Local.
+
+	loc := time.Local
+	for _, param := range params {
+		if strings.HasPrefix(strings.ToUpper(param), "TZID=") {
+			var err error
+			loc, err = loadLocation(strings.Trim(param[5:], `"`), loader)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("Not a valid TZID: %s", param)
+			}
+		}
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		loc = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	}
+
+	layout := "20060102T150405"
+	if len(value) == 8 {
+		layout = "20060102"
+	}
+
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Not a valid iCalendar time: %s", value)
+	}
+	return t, nil
+}
+
+func parseRRuleList(value string, min, max int, negative bool) ([]int, error) {
+	// Parse a comma separated list of values between min and max. If
+	// negative, values between -max and -1 count back from the end.
+
+	result := []int{}
+	for _, s := range strings.Split(value, ",") {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad value %s", s)
+		}
+		if v < 0 && !negative {
+			return nil, fmt.Errorf("negative values count from the end, which isn't supported")
+		}
+		if (v < min || v > max) && !(v < 0 && v >= -max) {
+			return nil, fmt.Errorf("values must be between %d and %d", min, max)
+		}
+		result = append(result, v)
+	}
+
+	return intersect(result, result), nil
+}
+
+func parseRRuleWeekdays(value string) ([]int, []nthWeekday, error) {
+	// Parse a BYDAY list, such as "MO,WE" or "1FR,-1FR", into weekdays and
+	// numbered weekdays. The weekdays are nil if the list holds every day.
+
+	re := regexp.MustCompile(`^([+-]?[0-9]{1,2})?(SU|MO|TU|WE|TH|FR|SA)$`)
+
+	days := map[int]bool{}
+	var nth []nthWeekday
+	for _, s := range strings.Split(value, ",") {
+		submatches := re.FindStringSubmatch(s)
+		if submatches == nil {
+			return nil, nil, fmt.Errorf("bad BYDAY %s", s)
+		}
+
+		weekday := 0
+		for i, day := range rruleWeekdays {
+			if day == submatches[2] {
+				weekday = i
+			}
+		}
+
+		if submatches[1] == "" {
+			days[weekday] = true
+			continue
+		}
+		n, _ := strconv.Atoi(submatches[1])
+		if n == 0 || n > 53 || n < -53 {
+			return nil, nil, fmt.Errorf("bad BYDAY %s", s)
+		}
+		nth = append(nth, nthWeekday{weekday, n})
+	}
+
+	switch len(days) {
+	case 0:
+		return nil, nth, nil
+	case 7:
+		// Numbered weekdays don't matter if every day matches.
+		return nil, nil, nil
+	}
+	return weekdayList(days), nth, nil
+}
+
+func rruleInterval(field, start, interval int) ([]int, error) {
+	// Expand a FREQ with an INTERVAL into a list of values for its field,
+	// which is only possible if the interval evenly divides the number of
+	// values in the field.
+
+	first, count := 0, 0
+	switch field {
+	case RRULE_MONTH:
+		first, count = 1, 12
+	case RRULE_HOUR:
+		count = 24
+	case RRULE_MINUTE, RRULE_SECOND:
+		count = 60
+	default:
+		return nil, fmt.Errorf("TimeGlobs can't skip years or days")
+	}
+
+	if count%interval != 0 {
+		return nil, fmt.Errorf("the interval must evenly divide %d", count)
+	}
+
+	result := []int{}
+	for v := first + (start-first)%interval; v < first+count; v += interval {
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func intersect(values, allowed []int) []int {
+	// Return the sorted, unique values which are also in allowed, or all of
+	// them if allowed is nil.
+
+	ok := map[int]bool{}
+	for _, v := range allowed {
+		ok[v] = true
+	}
+
+	seen := map[int]bool{}
+	result := []int{}
+	for _, v := range values {
+		if (allowed == nil || ok[v]) && !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+
+	sort.Ints(result)
+	return result
+}
+
+func (tg *TimeGlob) ToRRule(start time.Time) (string, error) {
+	// Write the glob as DTSTART and RRULE content lines, separated by a
+	// newline, which ParseRRule would convert back into an equivalent glob.
+	// DTSTART is the first match at, or after start.
+	//
+	// Globs with explicit years, fractional seconds, nearest weekdays, or
+	// which match either the day or the weekday can't be represented, nor
+	// can timezones without a name other calendars could look up, such as
+	// fixed offsets. These return an error listing each problem.
+
+	problems := []string{}
+
+	if tg.year != nil {
+		problems = append(problems, "year: RRULEs can't list years")
+	}
+	if !(len(tg.millisecond) == 1 && tg.millisecond[0] == 0) {
+		problems = append(problems, "millisecond: RRULEs have no fractional seconds")
+	}
+	if tg.location != time.UTC && tg.location != time.Local {
+		if !namedLocation(tg.location) {
+			problems = append(problems, fmt.Sprintf("location: TZIDs must name a timezone, not %q", tg.location.String()))
+		}
+	}
+
+	rules := tg.rules
+	if rules == nil {
+		rules = &dayRules{}
+	}
+	if rules.nearest != nil {
+		problems = append(problems, "day: RRULEs have no nearest weekday")
+	}
+	if rules.or {
+		problems = append(problems, "weekday: RRULEs only match days which match both BYMONTHDAY and BYDAY")
+	}
+
+	fields := [][]int{tg.year, tg.month, tg.day, tg.hour, tg.minute, tg.second}
+
+	// Step through the finest wildcard field, and list the others.
+	freq := RRULE_YEAR
+	for i, values := range fields {
+		if values == nil {
+			freq = i
+		}
+	}
+
+	frequency := rruleFrequencies[freq]
+	switch {
+	case rules.nth != nil && freq > RRULE_DAY:
+		problems = append(problems, "weekday: RRULEs only number weekdays in MONTHLY or YEARLY rules")
+	case rules.nth != nil && freq == RRULE_DAY && tg.month == nil:
+		frequency = "MONTHLY"
+	case rules.nth != nil && freq == RRULE_DAY:
+		frequency = "YEARLY"
+	case tg.weekday != nil && freq == RRULE_DAY:
+		frequency = "WEEKLY"
+	}
+
+	parts := []string{"FREQ=" + frequency}
+	for i, values := range fields {
+		if i == RRULE_DAY {
+			parts = append(parts, tg.formatRRuleWeekdays()...)
+		}
+		if i == RRULE_YEAR || values == nil {
+			continue
+		}
+
+		field := rruleFields[i]
+		text := []string{}
+		for _, v := range values {
+			if (v < field.min || v > field.max) && !(i == RRULE_DAY && v < 0 && v >= -field.max) {
+				problems = append(problems, fmt.Sprintf("%s: values must be between %d and %d", field.part, field.min, field.max))
+				break
+			}
+			text = append(text, strconv.Itoa(v))
+		}
+		parts = append(parts, field.part+"="+strings.Join(text, ","))
+	}
+
+	if len(problems) > 0 {
+		return "", fmt.Errorf("TimeGlob can't be represented as an RRULE: %s", strings.Join(problems, "; "))
+	}
+
+	first := tg.Next(start.Add(-time.Nanosecond))
+	if first == UNKNOWN {
+		return "", fmt.Errorf("TimeGlob has no matches after %s", start)
+	}
+
+	return formatICalTime("DTSTART", first.In(tg.location)) + "\nRRULE:" + strings.Join(parts, ";"), nil
+}
+
+func (tg *TimeGlob) formatRRuleWeekdays() []string {
+	// The BYDAY part for the weekdays of the glob, if it has any.
+
+	text := []string{}
+	for _, d := range tg.weekday {
+		text = append(text, rruleWeekdays[d])
+	}
+	if tg.rules != nil {
+		for _, n := range tg.rules.nth {
+			text = append(text, strconv.Itoa(n.n)+rruleWeekdays[n.weekday])
+		}
+	}
+
+	if len(text) == 0 {
+		return nil
+	}
+	return []string{"BYDAY=" + strings.Join(text, ",")}
+}
+
+func formatICalTime(name string, t time.Time) string {
+	// Write a DATE-TIME content line, with a TZID unless t is in UTC or Local.
+	// TZIDs with colons, such as "+05:30", are quoted.
+
+	switch t.Location() {
+	case time.UTC:
+		return name + ":" + t.Format("20060102T150405Z")
+	case time.Local:
+		return name + ":" + t.Format("20060102T150405")
+	}
+
+	tzid := t.Location().String()
+	if strings.ContainsAny(tzid, ":;,") {
+		tzid = `"` + tzid + `"`
+	}
+	return name + ";TZID=" + tzid + ":" + t.Format("20060102T150405")
+}
@@ -0,0 +1,294 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func rruleMatchesGlob(c *check.C, text string, glob string) {
+	// The recurrence should parse to the same TimeGlob as glob.
+
+	expected, err := Parse(glob)
+	c.Assert(err, check.IsNil)
+
+	tg, err := ParseRRule(text)
+	c.Check(err, check.IsNil, check.Commentf(text))
+	c.Check(tg, check.DeepEquals, expected, check.Commentf(text))
+}
+
+func (suite *MySuite) TestParseRRule(c *check.C) {
+	// Examples from RFC 5545, section 3.8.5.3, without COUNT or UNTIL.
+	start := "DTSTART;TZID=America/New_York:19970902T090000\n"
+
+	rruleMatchesGlob(c, start+"RRULE:FREQ=DAILY", "9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=YEARLY;BYMONTH=1;BYDAY=SU,MO,TU,WE,TH,FR,SA", "1/* 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=MONTHLY;BYMONTHDAY=2,15", "*/2,15 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=YEARLY;BYMONTH=6,7", "6,7/2 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=DAILY;BYHOUR=9,10,11,12,13,14,15,16;BYMINUTE=0,20,40",
+		"9,10,11,12,13,14,15,16:0,20,40 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=MINUTELY;INTERVAL=20;BYHOUR=9,10,11,12,13,14,15,16",
+		"9,10,11,12,13,14,15,16:0,20,40 America/New_York")
+
+	// DTSTART supplies the values the rule doesn't.
+	rruleMatchesGlob(c, start+"RRULE:FREQ=MONTHLY", "*/2 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=YEARLY", "9/2 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=YEARLY;BYMONTHDAY=1", "*/1 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=HOURLY", "*:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR,SA,SU", "9:00 America/New_York")
+
+	// Intervals which evenly divide the next unit become lists.
+	rruleMatchesGlob(c, start+"RRULE:FREQ=HOURLY;INTERVAL=6", "3,9,15,21:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=MONTHLY;INTERVAL=3", "3,6,9,12/2 9:00 America/New_York")
+	rruleMatchesGlob(c, start+"RRULE:FREQ=HOURLY;INTERVAL=3;BYHOUR=9,10,11,12", "9,12:00 America/New_York")
+
+	// Timezones come from DTSTART.
+	rruleMatchesGlob(c, "DTSTART:19970902T090000Z\nRRULE:FREQ=DAILY", "9:00 UTC")
+	rruleMatchesGlob(c, "DTSTART:19970902T090000\r\nRRULE:FREQ=DAILY\r\n", "9:00")
+	rruleMatchesGlob(c, "DTSTART;VALUE=DATE:19970902\nRRULE:FREQ=DAILY", "0:00")
+	rruleMatchesGlob(c, "RRULE:FREQ=DAILY\nDTSTART:19970902T093015Z", "9:30:15 UTC")
+}
+
+func rruleFirings(c *check.C, date string, rule string, count int) []string {
+	// The first count matches of an RRULE starting at 9:00 on date in New
+	// York. TimeGlobs have no start, so this searches from midnight.
+
+	tg, err := ParseRRule("DTSTART;TZID=America/New_York:" + date + "T090000\nRRULE:" + rule)
+	c.Assert(err, check.IsNil, check.Commentf(rule))
+
+	from, err := time.ParseInLocation("20060102", date, tg.location)
+	c.Assert(err, check.IsNil)
+
+	result := []string{}
+	for _, t := range tg.NextN(from, count) {
+		result = append(result, t.Format("Mon 2006-01-02 15:04"))
+	}
+	return result
+}
+
+func (suite *MySuite) TestParseRRuleWeekdays(c *check.C) {
+	// Examples from RFC 5545, section 3.8.5.3, without COUNT or UNTIL.
+
+	// Weekly on Tuesday and Thursday.
+	c.Check(rruleFirings(c, "19970902", "FREQ=WEEKLY;WKST=SU;BYDAY=TU,TH", 6), check.DeepEquals, []string{
+		"Tue 1997-09-02 09:00", "Thu 1997-09-04 09:00", "Tue 1997-09-09 09:00",
+		"Thu 1997-09-11 09:00", "Tue 1997-09-16 09:00", "Thu 1997-09-18 09:00",
+	})
+
+	// Weekly, on the weekday of DTSTART.
+	c.Check(rruleFirings(c, "19970902", "FREQ=WEEKLY", 3), check.DeepEquals, []string{
+		"Tue 1997-09-02 09:00", "Tue 1997-09-09 09:00", "Tue 1997-09-16 09:00",
+	})
+
+	// Every weekday.
+	c.Check(rruleFirings(c, "19970902", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", 5), check.DeepEquals, []string{
+		"Tue 1997-09-02 09:00", "Wed 1997-09-03 09:00", "Thu 1997-09-04 09:00",
+		"Fri 1997-09-05 09:00", "Mon 1997-09-08 09:00",
+	})
+
+	// Monthly on the first Friday.
+	c.Check(rruleFirings(c, "19970905", "FREQ=MONTHLY;BYDAY=1FR", 6), check.DeepEquals, []string{
+		"Fri 1997-09-05 09:00", "Fri 1997-10-03 09:00", "Fri 1997-11-07 09:00",
+		"Fri 1997-12-05 09:00", "Fri 1998-01-02 09:00", "Fri 1998-02-06 09:00",
+	})
+
+	// Monthly on the first and last Sunday.
+	c.Check(rruleFirings(c, "19970907", "FREQ=MONTHLY;BYDAY=1SU,-1SU", 6), check.DeepEquals, []string{
+		"Sun 1997-09-07 09:00", "Sun 1997-09-28 09:00", "Sun 1997-10-05 09:00",
+		"Sun 1997-10-26 09:00", "Sun 1997-11-02 09:00", "Sun 1997-11-30 09:00",
+	})
+
+	// Monthly on the second to last Monday.
+	c.Check(rruleFirings(c, "19970922", "FREQ=MONTHLY;BYDAY=-2MO", 6), check.DeepEquals, []string{
+		"Mon 1997-09-22 09:00", "Mon 1997-10-20 09:00", "Mon 1997-11-17 09:00",
+		"Mon 1997-12-22 09:00", "Mon 1998-01-19 09:00", "Mon 1998-02-16 09:00",
+	})
+
+	// Monthly on the third to last day.
+	c.Check(rruleFirings(c, "19970928", "FREQ=MONTHLY;BYMONTHDAY=-3", 6), check.DeepEquals, []string{
+		"Sun 1997-09-28 09:00", "Wed 1997-10-29 09:00", "Fri 1997-11-28 09:00",
+		"Mon 1997-12-29 09:00", "Thu 1998-01-29 09:00", "Thu 1998-02-26 09:00",
+	})
+
+	// Monthly on the first and last day.
+	c.Check(rruleFirings(c, "19970930", "FREQ=MONTHLY;BYMONTHDAY=1,-1", 6), check.DeepEquals, []string{
+		"Tue 1997-09-30 09:00", "Wed 1997-10-01 09:00", "Fri 1997-10-31 09:00",
+		"Sat 1997-11-01 09:00", "Sun 1997-11-30 09:00", "Mon 1997-12-01 09:00",
+	})
+
+	// Every Friday the 13th.
+	c.Check(rruleFirings(c, "19970902", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", 5), check.DeepEquals, []string{
+		"Fri 1998-02-13 09:00", "Fri 1998-03-13 09:00", "Fri 1998-11-13 09:00",
+		"Fri 1999-08-13 09:00", "Fri 2000-10-13 09:00",
+	})
+
+	// Every Thursday in March.
+	c.Check(rruleFirings(c, "19970313", "FREQ=YEARLY;BYMONTH=3;BYDAY=TH", 6), check.DeepEquals, []string{
+		"Thu 1997-03-13 09:00", "Thu 1997-03-20 09:00", "Thu 1997-03-27 09:00",
+		"Thu 1998-03-05 09:00", "Thu 1998-03-12 09:00", "Thu 1998-03-19 09:00",
+	})
+
+	// Yearly on the last Friday in November.
+	c.Check(rruleFirings(c, "19971128", "FREQ=YEARLY;BYMONTH=11;BYDAY=-1FR", 3), check.DeepEquals, []string{
+		"Fri 1997-11-28 09:00", "Fri 1998-11-27 09:00", "Fri 1999-11-26 09:00",
+	})
+}
+
+func (suite *MySuite) TestParseRRuleUnsupported(c *check.C) {
+	// More examples from RFC 5545, which TimeGlobs can't represent.
+	start := "DTSTART;TZID=America/New_York:19970902T090000\n"
+
+	bad := map[string]string{
+		"FREQ=DAILY;COUNT=10":                           "COUNT=10 \\(TimeGlobs have no end\\)",
+		"FREQ=DAILY;UNTIL=19971224T000000Z":             "UNTIL=19971224T000000Z \\(TimeGlobs have no end\\)",
+		"FREQ=DAILY;INTERVAL=2":                         "INTERVAL=2 \\(TimeGlobs can't skip years or days\\)",
+		"FREQ=MINUTELY;INTERVAL=90":                     "INTERVAL=90 \\(the interval must evenly divide 60\\)",
+		"FREQ=YEARLY;INTERVAL=2":                        "INTERVAL=2 \\(TimeGlobs can't skip years or days\\)",
+		"FREQ=WEEKLY;INTERVAL=2;WKST=SU;BYDAY=TU,TH":    "INTERVAL \\(TimeGlobs can't skip weeks\\)",
+		"FREQ=YEARLY;BYDAY=20MO":                        "BYDAY \\(TimeGlobs can't number weekdays of the year\\)",
+		"FREQ=MONTHLY;BYDAY=6MO":                        "BYDAY \\(a month has at most 5 of each weekday\\)",
+		"FREQ=YEARLY;BYYEARDAY=1,100,200":               "BYYEARDAY=1,100,200 \\(not supported\\)",
+		"FREQ=YEARLY;BYWEEKNO=20":                       "BYWEEKNO=20 \\(not supported\\)",
+		"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1": "BYSETPOS=-1 \\(not supported\\)",
+	}
+	for rule, reason := range bad {
+		_, err := ParseRRule(start + "RRULE:" + rule)
+		c.Check(err, check.ErrorMatches, "RRULE can't be represented by a TimeGlob: "+reason, check.Commentf(rule))
+	}
+
+	_, err := ParseRRule(start + "RRULE:FREQ=DAILY\nEXDATE:19970903T090000")
+	c.Check(err, check.ErrorMatches, ".*EXDATE \\(unsupported property\\)")
+}
+
+func (suite *MySuite) TestParseRRuleBad(c *check.C) {
+	texts := []string{
+		"",
+		"RRULE:FREQ=DAILY",
+		"DTSTART:19970902T090000",
+		"DTSTART:bad\nRRULE:FREQ=DAILY",
+		"DTSTART;TZID=Bad/Zone:19970902T090000\nRRULE:FREQ=DAILY",
+		"DTSTART:19970902T090000\nRRULE:FREQ=FORTNIGHTLY",
+		"DTSTART:19970902T090000\nRRULE:BYHOUR=9",
+		"DTSTART:19970902T090000\nRRULE:FREQ=DAILY;INTERVAL=0",
+		"DTSTART:19970902T090000\nRRULE:FREQ=DAILY;BYHOUR",
+		"DTSTART:19970902T090000\nRRULE:FREQ=DAILY;BYFOO=1",
+		"DTSTART:19970902T090000\nFREQ=DAILY",
+		"DTSTART:19970902T090000\nRRULE:FREQ=DAILY;BYDAY=XX",
+		"DTSTART:19970902T090000\nRRULE:FREQ=DAILY;BYDAY=0MO",
+		"DTSTART:19970902T090000\nRRULE:FREQ=WEEKLY;BYDAY=1MO",
+	}
+
+	for _, text := range texts {
+		_, err := ParseRRule(text)
+		c.Check(err, check.ErrorMatches, "Not a valid .*", check.Commentf(text))
+	}
+
+	_, err := ParseRRule("DTSTART:19970902T090000\nRRULE:FREQ=DAILY;BYHOUR=24")
+	c.Check(err, check.ErrorMatches, ".*BYHOUR=24 \\(values must be between 0 and 23\\)")
+}
+
+func (suite *MySuite) TestToRRule(c *check.C) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		glob     string
+		expected string
+	}{
+		{"9:30 UTC", "DTSTART:20160101T093000Z\nRRULE:FREQ=DAILY;BYHOUR=9;BYMINUTE=30;BYSECOND=0"},
+		{"*:0,15,30,45 UTC", "DTSTART:20160101T000000Z\nRRULE:FREQ=HOURLY;BYMINUTE=0,15,30,45;BYSECOND=0"},
+		{"*:*:* UTC", "DTSTART:20160101T000000Z\nRRULE:FREQ=SECONDLY"},
+		{"*/1,15 9:00 UTC", "DTSTART:20160101T090000Z\nRRULE:FREQ=MONTHLY;BYMONTHDAY=1,15;BYHOUR=9;BYMINUTE=0;BYSECOND=0"},
+		{"6,7/2 9:00 America/New_York",
+			"DTSTART;TZID=America/New_York:20160602T090000\nRRULE:FREQ=YEARLY;BYMONTH=6,7;BYMONTHDAY=2;BYHOUR=9;BYMINUTE=0;BYSECOND=0"},
+		{"Mon-Fri 9:00 America/New_York",
+			"DTSTART;TZID=America/New_York:20160101T090000\nRRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;BYHOUR=9;BYMINUTE=0;BYSECOND=0"},
+		{"Sat,Sun 3/* *:00 UTC", "DTSTART:20160305T000000Z\nRRULE:FREQ=HOURLY;BYMONTH=3;BYDAY=SU,SA;BYMINUTE=0;BYSECOND=0"},
+		{"Fri */13 0:00 UTC", "DTSTART:20160513T000000Z\nRRULE:FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;BYHOUR=0;BYMINUTE=0;BYSECOND=0"},
+	}
+
+	for _, t := range cases {
+		tg, err := Parse(t.glob)
+		c.Assert(err, check.IsNil)
+
+		text, err := tg.ToRRule(start)
+		c.Check(err, check.IsNil, check.Commentf(t.glob))
+		c.Check(text, check.Equals, t.expected, check.Commentf(t.glob))
+
+		// And back again.
+		back, err := ParseRRule(text)
+		c.Check(err, check.IsNil, check.Commentf(text))
+		c.Check(back, check.DeepEquals, tg, check.Commentf(t.glob))
+	}
+
+	// Numbered weekdays and days counting from the end of the month, which
+	// globs can't write, go back and forth too.
+	rules := []string{
+		"DTSTART:20160129T090000Z\nRRULE:FREQ=MONTHLY;BYDAY=-1FR;BYHOUR=9;BYMINUTE=0;BYSECOND=0",
+		"DTSTART:20160131T090000Z\nRRULE:FREQ=MONTHLY;BYMONTHDAY=-1;BYHOUR=9;BYMINUTE=0;BYSECOND=0",
+		"DTSTART:20161124T090000Z\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;BYHOUR=9;BYMINUTE=0;BYSECOND=0",
+	}
+	for _, rule := range rules {
+		tg, err := ParseRRule(rule)
+		c.Assert(err, check.IsNil, check.Commentf(rule))
+
+		text, err := tg.ToRRule(start)
+		c.Check(err, check.IsNil, check.Commentf(rule))
+		c.Check(text, check.Equals, rule)
+	}
+
+	// DTSTART is the first match at, or after the start.
+	tg, err := Parse("*:00 UTC")
+	c.Assert(err, check.IsNil)
+	text, err := tg.ToRRule(start)
+	c.Check(err, check.IsNil)
+	c.Check(text, check.Equals, "DTSTART:20160101T000000Z\nRRULE:FREQ=HOURLY;BYMINUTE=0;BYSECOND=0")
+	text, err = tg.ToRRule(start.Add(time.Second))
+	c.Check(err, check.IsNil)
+	c.Check(text, check.Equals, "DTSTART:20160101T010000Z\nRRULE:FREQ=HOURLY;BYMINUTE=0;BYSECOND=0")
+
+	// Local globs have a floating DTSTART.
+	tg, err = Parse("0:00")
+	c.Assert(err, check.IsNil)
+	text, err = tg.ToRRule(time.Date(2016, 1, 1, 0, 0, 0, 0, time.Local))
+	c.Check(err, check.IsNil)
+	c.Check(text, check.Equals, "DTSTART:20160101T000000\nRRULE:FREQ=DAILY;BYHOUR=0;BYMINUTE=0;BYSECOND=0")
+}
+
+func (suite *MySuite) TestToRRuleErrors(c *check.C) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tg, err := Parse("2016/1/1 9:30:15.5 UTC")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches,
+		"TimeGlob can't be represented as an RRULE: year: RRULEs can't list years; millisecond: RRULEs have no fractional seconds")
+
+	tg, err = Parse("*/* 25:0 UTC")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as an RRULE: BYHOUR: values must be between 0 and 23")
+
+	tg, err = Parse("9:00 +05:30")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches, `TimeGlob can't be represented as an RRULE: location: TZIDs must name a timezone, not "\+05:30"`)
+
+	tg, err = ParseCron("0 9 15W * *", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as an RRULE: day: RRULEs have no nearest weekday")
+
+	tg, err = ParseCron("0 9 1 * MON", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as an RRULE: weekday: RRULEs only match days which match both BYMONTHDAY and BYDAY")
+
+	tg, err = ParseCron("0 * * * 5#3", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches, "TimeGlob can't be represented as an RRULE: weekday: RRULEs only number weekdays in MONTHLY or YEARLY rules")
+
+	tg, err = Parse("2/30 0:00 UTC")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToRRule(start)
+	c.Check(err, check.ErrorMatches, "TimeGlob has no matches after .*")
+}
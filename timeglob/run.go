@@ -0,0 +1,148 @@
+package timeglob
+
+import (
+	"context"
+	"sync"
+)
+
+// What Run does when the previous run of fn is still going at the next match.
+type OverlapPolicy int
+
+const (
+	// Remember a single match, and run fn again as soon as the previous run
+	// finishes. Further matches while waiting are dropped. This is the
+	// default, and how a Ticker behaves if the receiver falls behind.
+	QUEUE_OVERLAP OverlapPolicy = iota
+
+	// Start another run right away, so runs can overlap.
+	ALLOW_OVERLAP
+
+	// Drop the match.
+	SKIP_OVERLAP
+
+	// Cancel the context of the previous run, and start a new run once it
+	// returns.
+	CANCEL_OVERLAP
+)
+
+// Options which control Run.
+type RunOptions struct {
+	// What to do with matches while fn is running.
+	Overlap OverlapPolicy
+
+	// Source of the current time, and timers. If nil, RealClock is used.
+	Clock Clock
+}
+
+// Tracks the runs of fn for Run.
+type runner struct {
+	lock    sync.Mutex
+	ctx     context.Context
+	fn      func(ctx context.Context)
+	overlap OverlapPolicy
+	running int
+	queued  bool
+
+	// The cancel function and done channel of the latest run.
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	wait sync.WaitGroup
+}
+
+func (tg *TimeGlob) Run(ctx context.Context, fn func(ctx context.Context), overlap OverlapPolicy) {
+	// Call fn in its own goroutine on each match of the glob, until ctx is
+	// cancelled. The overlap policy chooses what happens if a run is still
+	// going at the next match. Each run gets a context derived from ctx.
+	// Returns once ctx is cancelled, and all runs have returned.
+	tg.RunWithOptions(ctx, fn, RunOptions{Overlap: overlap})
+}
+
+func (tg *TimeGlob) RunWithOptions(ctx context.Context, fn func(ctx context.Context), options RunOptions) {
+	// Same as Run, with more control.
+	RunSchedule(ctx, tg, fn, options)
+}
+
+func RunSchedule(ctx context.Context, s Schedule, fn func(ctx context.Context), options RunOptions) {
+	// Same as RunWithOptions, for any Schedule.
+
+	r := &runner{ctx: ctx, fn: fn, overlap: options.Overlap}
+
+	ticker := newTicker(s, TickerOptions{Clock: options.Clock, Context: ctx}, r.match)
+
+	<-ctx.Done()
+
+	// Once Stop returns, no more matches can start a run.
+	ticker.Stop()
+	r.wait.Wait()
+}
+
+func (r *runner) match() {
+	// Handle a single match of the glob.
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	switch r.overlap {
+	case ALLOW_OVERLAP:
+		r.start(nil)
+
+	case SKIP_OVERLAP:
+		if r.running == 0 {
+			r.start(nil)
+		}
+
+	case CANCEL_OVERLAP:
+		if r.running != 0 {
+			r.cancel()
+			r.start(r.done)
+		} else {
+			r.start(nil)
+		}
+
+	default:
+		if r.running == 0 {
+			r.start(nil)
+		} else {
+			r.queued = true
+		}
+	}
+}
+
+func (r *runner) start(after chan struct{}) {
+	// Start a run in its own goroutine. If after isn't nil, the run waits for
+	// it to be closed first. Must hold the lock.
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	done := make(chan struct{})
+
+	r.running++
+	r.cancel = cancel
+	r.done = done
+	r.wait.Add(1)
+
+	go func() {
+		defer r.wait.Done()
+
+		if after != nil {
+			<-after
+		}
+
+		// A run can be cancelled before it starts.
+		if ctx.Err() == nil {
+			r.fn(ctx)
+		}
+
+		cancel()
+		close(done)
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		r.running--
+		if r.queued && r.ctx.Err() == nil {
+			r.queued = false
+			r.start(nil)
+		}
+	}()
+}
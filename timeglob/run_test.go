@@ -0,0 +1,151 @@
+package timeglob_test
+
+import (
+	"context"
+	"github.com/DonGar/go-timeglob/timeglob"
+	"github.com/DonGar/go-timeglob/timeglob/timeglobtest"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+// A function for Run, which reports what each run does on events, and returns
+// when release is sent to, or it's cancelled.
+type runRecorder struct {
+	events  chan string
+	release chan struct{}
+}
+
+func newRunRecorder() *runRecorder {
+	return &runRecorder{make(chan string, 20), make(chan struct{})}
+}
+
+func (r *runRecorder) run(ctx context.Context) {
+	r.events <- "start"
+	select {
+	case <-r.release:
+		r.events <- "done"
+	case <-ctx.Done():
+		r.events <- "cancelled"
+	}
+}
+
+func (r *runRecorder) expect(c *check.C, expected ...string) {
+	// Validate the next events, in order.
+	for _, e := range expected {
+		select {
+		case event := <-r.events:
+			c.Check(event, check.Equals, e)
+		case <-time.After(10 * time.Second):
+			c.Fatalf("No event, expected %s", e)
+		}
+	}
+}
+
+func (r *runRecorder) expectNone(c *check.C) {
+	select {
+	case event := <-r.events:
+		c.Errorf("Unexpected event %s", event)
+	default:
+	}
+}
+
+func startRun(c *check.C, overlap timeglob.OverlapPolicy, fn func(context.Context)) (*timeglobtest.FakeClock, func()) {
+	// Call Run in the background on an hourly glob, and wait until it's
+	// ready. Returns the clock, and a function which stops Run and waits for
+	// it to return.
+
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0))
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+
+	go func() {
+		tg.RunWithOptions(ctx, fn, timeglob.RunOptions{Overlap: overlap, Clock: clock})
+		close(finished)
+	}()
+
+	for clock.Pending() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	return clock, func() {
+		cancel()
+		<-finished
+	}
+}
+
+func (suite *TickerSuite) TestRunQueue(c *check.C) {
+	r := newRunRecorder()
+	clock, stop := startRun(c, timeglob.QUEUE_OVERLAP, r.run)
+
+	clock.Advance(time.Hour)
+	r.expect(c, "start")
+
+	// Two matches while running only queue one more run.
+	clock.Advance(2 * time.Hour)
+	r.release <- struct{}{}
+	r.expect(c, "done", "start")
+	r.release <- struct{}{}
+	r.expect(c, "done")
+
+	stop()
+	r.expectNone(c)
+}
+
+func (suite *TickerSuite) TestRunAllow(c *check.C) {
+	r := newRunRecorder()
+	clock, stop := startRun(c, timeglob.ALLOW_OVERLAP, r.run)
+
+	clock.Advance(time.Hour)
+	clock.Advance(time.Hour)
+	r.expect(c, "start", "start")
+
+	r.release <- struct{}{}
+	r.expect(c, "done")
+
+	// Stopping cancels the remaining run, and waits for it.
+	stop()
+	r.expect(c, "cancelled")
+	r.expectNone(c)
+}
+
+func (suite *TickerSuite) TestRunSkip(c *check.C) {
+	r := newRunRecorder()
+	clock, stop := startRun(c, timeglob.SKIP_OVERLAP, r.run)
+
+	clock.Advance(time.Hour)
+	r.expect(c, "start")
+
+	// Skipped, since the first run is still going.
+	clock.Advance(time.Hour)
+	r.release <- struct{}{}
+	r.expect(c, "done")
+
+	clock.Advance(time.Hour)
+	r.expect(c, "start")
+	r.release <- struct{}{}
+	r.expect(c, "done")
+
+	stop()
+	r.expectNone(c)
+}
+
+func (suite *TickerSuite) TestRunCancel(c *check.C) {
+	r := newRunRecorder()
+	clock, stop := startRun(c, timeglob.CANCEL_OVERLAP, r.run)
+
+	clock.Advance(time.Hour)
+	r.expect(c, "start")
+
+	// The new run starts after the previous run returns.
+	clock.Advance(time.Hour)
+	r.expect(c, "cancelled", "start")
+
+	r.release <- struct{}{}
+	r.expect(c, "done")
+
+	stop()
+	r.expectNone(c)
+}
@@ -0,0 +1,104 @@
+package timeglob
+
+import (
+	"time"
+)
+
+// A set of times to tick on. TimeGlob and RepeatingInterval are Schedules, and
+// other types can implement it to reuse Ticker, Run and the scheduler
+// package with their own rules.
+//
+// Next must return a time after now, and Prev a time at, or before now, or
+// UNKNOWN if there isn't one. A Schedule may also implement Matches, Count,
+// BetweenFunc or Location, as TimeGlob does, and they are used instead of
+// the slower versions built on Next and Prev.
+type Schedule interface {
+	Next(now time.Time) time.Time
+	Prev(now time.Time) time.Time
+}
+
+// Optional interfaces for Schedules.
+type matcher interface {
+	Matches(t time.Time) bool
+}
+
+type counter interface {
+	Count(start, end time.Time) int
+}
+
+type betweenFuncer interface {
+	BetweenFunc(start, end time.Time, fn func(time.Time) bool)
+}
+
+type locator interface {
+	Location() *time.Location
+}
+
+func (tg *TimeGlob) Location() *time.Location {
+	// The timezone the glob is matched in.
+	return tg.location
+}
+
+func ScheduleMatches(s Schedule, t time.Time) bool {
+	// Is t an exact match for the schedule? Uses the schedule's own Matches
+	// if it has one.
+
+	if m, ok := s.(matcher); ok {
+		return m.Matches(t)
+	}
+	return t != UNKNOWN && s.Prev(t).Equal(t)
+}
+
+func ScheduleBetweenFunc(s Schedule, start, end time.Time, fn func(time.Time) bool) {
+	// Call fn with each match which is at, or after start, and before end, in
+	// order. Stops early if fn returns false. Uses the schedule's own
+	// BetweenFunc if it has one.
+
+	if b, ok := s.(betweenFuncer); ok {
+		b.BetweenFunc(start, end, fn)
+		return
+	}
+
+	if !start.Before(end) {
+		return
+	}
+
+	t := s.Next(start.Add(-time.Nanosecond))
+	for t != UNKNOWN && t.Before(end) {
+		if !fn(t.In(start.Location())) {
+			return
+		}
+
+		// Guard against a Schedule which doesn't move forwards.
+		next := s.Next(t)
+		if !next.After(t) {
+			return
+		}
+		t = next
+	}
+}
+
+func ScheduleCount(s Schedule, start, end time.Time) int {
+	// Count the matches which are at, or after start, and before end. Uses
+	// the schedule's own Count if it has one.
+
+	if c, ok := s.(counter); ok {
+		return c.Count(start, end)
+	}
+
+	count := 0
+	ScheduleBetweenFunc(s, start, end, func(time.Time) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+func scheduleLocation(s Schedule) *time.Location {
+	// The timezone to report ticks in, or nil to use the clock's.
+
+	if l, ok := s.(locator); ok {
+		return l.Location()
+	}
+	return nil
+}
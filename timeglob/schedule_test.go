@@ -0,0 +1,80 @@
+package timeglob_test
+
+import (
+	"github.com/DonGar/go-timeglob/timeglob"
+	"github.com/DonGar/go-timeglob/timeglob/timeglobtest"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+// A Schedule with only Next and Prev, so the helpers fall back on them.
+type everyTenMinutes struct{}
+
+func (everyTenMinutes) Next(now time.Time) time.Time {
+	return now.Truncate(10 * time.Minute).Add(10 * time.Minute)
+}
+
+func (everyTenMinutes) Prev(now time.Time) time.Time {
+	return now.Truncate(10 * time.Minute)
+}
+
+func (suite *TickerSuite) TestScheduleHelpers(c *check.C) {
+	var s timeglob.Schedule = everyTenMinutes{}
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	minutes := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+
+	c.Check(timeglob.ScheduleMatches(s, minutes(20)), check.Equals, true)
+	c.Check(timeglob.ScheduleMatches(s, minutes(25)), check.Equals, false)
+	c.Check(timeglob.ScheduleMatches(s, timeglob.UNKNOWN), check.Equals, false)
+
+	// Start is included, and end isn't.
+	c.Check(timeglob.ScheduleCount(s, minutes(10), minutes(40)), check.Equals, 3)
+	c.Check(timeglob.ScheduleCount(s, minutes(40), minutes(10)), check.Equals, 0)
+
+	matches := []time.Time{}
+	timeglob.ScheduleBetweenFunc(s, minutes(5), minutes(60), func(t time.Time) bool {
+		matches = append(matches, t)
+		return len(matches) < 2
+	})
+	c.Check(matches, check.DeepEquals, []time.Time{minutes(10), minutes(20)})
+
+	// TimeGlobs use their own versions.
+	tg, err := timeglob.Parse("*:*:0 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(timeglob.ScheduleCount(tg, start, start.AddDate(1, 0, 0)), check.Equals, 366*24*60)
+}
+
+func (suite *TickerSuite) TestTickerSchedule(c *check.C) {
+	r, err := timeglob.ParseRepeatingInterval("R4/2016-01-01T00:00:00Z/PT90M")
+	c.Assert(err, check.IsNil)
+
+	start := time.Date(2016, 1, 1, 0, 10, 0, 0, time.UTC)
+	minutes := func(m int) time.Time { return time.Date(2016, 1, 1, 0, m, 0, 0, time.UTC) }
+
+	clock := timeglobtest.NewFakeClock(start)
+	ticker := timeglob.NewTicker(r, timeglob.TickerOptions{Clock: clock, Events: true})
+	defer ticker.Stop()
+
+	clock.AdvanceTo(minutes(90))
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: minutes(90), Fired: minutes(90), Sequence: 1})
+
+	// Missed matches are counted without a Count method.
+	clock.Set(minutes(275))
+	clock.Advance(0)
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: minutes(270), Fired: minutes(275), Late: 5 * time.Minute, Sequence: 3, Dropped: 1})
+
+	// There are no more matches.
+	clock.AdvanceTo(minutes(24 * 60))
+	select {
+	case event := <-ticker.Events:
+		c.Errorf("Unexpected event %v", event)
+	default:
+	}
+
+	// Reset back to a glob.
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+	ticker.Reset(tg)
+	clock.AdvanceTo(minutes(25 * 60))
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: minutes(25 * 60), Fired: minutes(25 * 60), Sequence: 4})
+}
@@ -0,0 +1,489 @@
+package timeglob
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemd's shorthands, and the expressions they stand for.
+var calendarShorthands = map[string]string{
+	"minutely":     "*-*-* *:*:00",
+	"hourly":       "*-*-* *:00:00",
+	"daily":        "*-*-* 00:00:00",
+	"monthly":      "*-*-01 00:00:00",
+	"weekly":       "Mon *-*-* 00:00:00",
+	"yearly":       "*-01-01 00:00:00",
+	"annually":     "*-01-01 00:00:00",
+	"quarterly":    "*-01,04,07,10-01 00:00:00",
+	"semiannually": "*-01,07-01 00:00:00",
+}
+
+// Returned by calendarField.parse for a repeating year without an end, which
+// would need a list of every later year.
+var errCalendarUnbounded = fmt.Errorf("years can't repeat forever")
+
+var calendarWeekdays = []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// A second with a fraction, such as "05.250".
+var calendarFractionRegexp = regexp.MustCompile(`^([0-9]+)\.([0-9]{1,6})$`)
+
+// A single component of an OnCalendar expression.
+type calendarField struct {
+	name     string
+	min, max int
+	width    int
+}
+
+var (
+	calendarYear     = calendarField{"year", 1970, 2199, 4}
+	calendarMonth    = calendarField{"month", 1, 12, 2}
+	calendarMonthDay = calendarField{"day", 1, 31, 2}
+	calendarLastDay  = calendarField{"last day", 1, 31, 2}
+	calendarHour     = calendarField{"hour", 0, 23, 2}
+	calendarMinute   = calendarField{"minute", 0, 59, 2}
+	calendarSecond   = calendarField{"second", 0, 59, 2}
+)
+
+func ParseOnCalendar(expr string) (*TimeGlob, error) {
+	return ParseOnCalendarWithOptions(expr, ParseOptions{})
+}
+
+func ParseOnCalendarWithOptions(expr string, options ParseOptions) (*TimeGlob, error) {
+	// Convert a systemd OnCalendar expression, such as
+	// "Mon..Fri *-*-* 09:00:00 Europe/Berlin", into a TimeGlob. Weekdays,
+	// lists, ".." ranges, "/" repetition, days counting back from the end of
+	// the month with "~", fractional seconds and shorthands like "daily" are
+	// supported. The timezone is Local unless the expression ends with one,
+	// which is found with options.LocationLoader, or as a fixed offset, in the
+	// same way as globs.
+	//
+	// Seconds with different fractions, such as "00.25,30.5", can't be
+	// represented, and return an error.
+
+	invalid := func(reason string) error {
+		return fmt.Errorf("Not a valid OnCalendar expression: %s (%s)", expr, reason)
+	}
+	unsupported := func(reason string) error {
+		return fmt.Errorf("OnCalendar expression can't be represented by a TimeGlob: %s (%s)", expr, reason)
+	}
+
+	result := new()
+	tokens := strings.Fields(expr)
+	if len(tokens) == 0 {
+		return nil, invalid("empty")
+	}
+
+	// A trailing timezone.
+	last := tokens[len(tokens)-1]
+	if !isCalendarWeekdays(last) && calendarShorthands[strings.ToLower(last)] == "" && !strings.ContainsAny(last[:1], "0123456789*") {
+		loc, err := loadLocation(last, options.LocationLoader)
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		result.location = loc
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	if len(tokens) == 1 {
+		if shorthand, ok := calendarShorthands[strings.ToLower(tokens[0])]; ok {
+			tokens = strings.Fields(shorthand)
+		}
+	}
+
+	if len(tokens) > 0 && isCalendarWeekdays(tokens[0]) {
+		weekdays, err := parseCalendarWeekdays(tokens[0])
+		if err != nil {
+			return nil, invalid(err.Error())
+		}
+		result.weekday = weekdays
+		tokens = tokens[1:]
+	}
+
+	dateText, timeText := "*-*-*", "00:00:00"
+	switch {
+	case len(tokens) == 1 && strings.Contains(tokens[0], ":"):
+		timeText = tokens[0]
+	case len(tokens) == 1:
+		dateText = tokens[0]
+	case len(tokens) == 2:
+		dateText, timeText = tokens[0], tokens[1]
+	default:
+		return nil, invalid("expected a date and a time")
+	}
+
+	// "~" separates the month from a day counting back from the end of the
+	// month, so "*-02~03" is the third last day of February.
+	dayField := calendarMonthDay
+	if i := strings.Index(dateText, "~"); i >= 0 {
+		dayField = calendarLastDay
+		dateText = dateText[:i] + "-" + dateText[i+1:]
+	}
+
+	dates := strings.Split(dateText, "-")
+	if len(dates) == 2 {
+		dates = append([]string{"*"}, dates...)
+	}
+	times := strings.Split(timeText, ":")
+	if len(times) == 2 {
+		times = append(times, "00")
+	}
+	if len(dates) != 3 || len(times) != 3 {
+		return nil, invalid("expected year-month-day hour:minute:second")
+	}
+
+	// Fractions of single seconds, like "05.250", become milliseconds, which
+	// must be the same for every second.
+	fractions := map[string]bool{}
+	seconds := strings.Split(times[2], ",")
+	for i, part := range seconds {
+		fraction := ""
+		if submatches := calendarFractionRegexp.FindStringSubmatch(part); submatches != nil {
+			seconds[i], fraction = submatches[1], submatches[2]
+		}
+		fractions[strings.TrimRight(fraction, "0")] = true
+	}
+	times[2] = strings.Join(seconds, ",")
+	if len(fractions) > 1 {
+		return nil, unsupported("seconds with different fractions")
+	}
+	if strings.Contains(strings.ReplaceAll(times[2], "..", ""), ".") {
+		return nil, unsupported("fractional seconds")
+	}
+	for fraction := range fractions {
+		if len(fraction) > 3 {
+			return nil, unsupported("fractions finer than a millisecond")
+		}
+		ms, _ := strconv.Atoi((fraction + "000")[:3])
+		result.millisecond = []int{ms}
+	}
+
+	var err error
+	parse := func(field calendarField, text string) []int {
+		values, e := field.parse(text)
+		if e != nil && err == nil {
+			err = e
+		}
+		return values
+	}
+
+	result.year = parse(calendarYear, dates[0])
+	result.month = parse(calendarMonth, dates[1])
+	result.day = parse(dayField, dates[2])
+	result.hour = parse(calendarHour, times[0])
+	result.minute = parse(calendarMinute, times[1])
+	result.second = parse(calendarSecond, times[2])
+
+	if err != nil {
+		if err == errCalendarUnbounded {
+			return nil, unsupported(err.Error())
+		}
+		return nil, invalid(err.Error())
+	}
+
+	if dayField == calendarLastDay && result.day != nil {
+		for i, d := range result.day {
+			result.day[i] = -d
+		}
+		sort.Ints(result.day)
+	}
+
+	return &result, nil
+}
+
+func isCalendarWeekdays(text string) bool {
+	// Does text look like a list of weekdays, such as "Mon..Fri,Sun"?
+
+	for _, part := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '.' }) {
+		if calendarWeekday(part) < 0 {
+			return false
+		}
+	}
+	return text != ""
+}
+
+func calendarWeekday(name string) int {
+	// The index of a weekday name, which may be abbreviated to 3 letters.
+
+	for i, day := range calendarWeekdays {
+		if strings.EqualFold(name, day) || strings.EqualFold(name, day[:3]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseCalendarWeekdays(text string) ([]int, error) {
+	// Parse a list of weekdays and ranges, such as "Mon..Fri,Sun", into a
+	// TimeGlob weekday list, or nil if it covers the whole week. Ranges run
+	// from Monday to Sunday, and can't wrap.
+
+	days := map[int]bool{}
+	for _, part := range strings.Split(text, ",") {
+		bounds := strings.Split(part, "..")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("bad weekdays %s", part)
+		}
+
+		low, high := calendarWeekday(bounds[0]), calendarWeekday(bounds[len(bounds)-1])
+		if low < 0 || high < 0 || low > high {
+			return nil, fmt.Errorf("bad weekdays %s", part)
+		}
+		for d := low; d <= high; d++ {
+			// calendarWeekdays starts on Monday, and TimeGlobs on Sunday.
+			days[(d+1)%7] = true
+		}
+	}
+	return weekdayList(days), nil
+}
+
+func (f calendarField) parse(text string) ([]int, error) {
+	// Parse a component with lists, ".." ranges and "/" repetition into a
+	// sorted list of values. Returns nil if it matches every value.
+
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(text, ",") {
+		rangeText, step := part, 0
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangeText = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("bad repetition in %s: %s", f.name, part)
+			}
+		}
+
+		var low, high int
+		var err error
+
+		switch {
+		case rangeText == "*":
+			low, high = f.min, f.max
+
+		case strings.Contains(rangeText, ".."):
+			bounds := strings.SplitN(rangeText, "..", 2)
+			low, err = f.value(bounds[0])
+			if err == nil {
+				high, err = f.value(bounds[1])
+			}
+
+		default:
+			low, err = f.value(rangeText)
+			high = low
+			if step > 0 {
+				// A value repeats until the end of the field, which for days
+				// counting back from the end of the month is the last day.
+				high = f.max
+				if f == calendarLastDay {
+					low, high = (low-1)%step+1, low
+				}
+				if f == calendarYear {
+					return nil, errCalendarUnbounded
+				}
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if low > high {
+			return nil, fmt.Errorf("backwards range in %s: %s", f.name, part)
+		}
+
+		if step == 0 {
+			step = 1
+		}
+		for v := low; v <= high; v += step {
+			values[v] = true
+		}
+	}
+
+	if len(values) == f.max-f.min+1 {
+		return nil, nil
+	}
+
+	result := []int{}
+	for v := range values {
+		result = append(result, v)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+func (f calendarField) value(text string) (int, error) {
+	// Parse a single number.
+
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("bad %s: %s", f.name, text)
+	}
+	return v, nil
+}
+
+func (tg *TimeGlob) ToOnCalendar() (string, error) {
+	// Write the glob as a systemd OnCalendar expression, in systemd's
+	// normalized form, such as "Mon..Fri *-*-01 09:00:00 Europe/Berlin", which
+	// ParseOnCalendar would convert back into an equivalent glob.
+	//
+	// Globs with several fractions of a second, days counting from both ends
+	// of the month, cron's numbered weekdays, nearest weekdays (W) or
+	// either-day matching, or timezones systemd can't look up by name, such
+	// as "+05:30" or "PDT", can't be represented, and return an error listing
+	// each problem.
+
+	problems := []string{}
+
+	if tg.rules != nil {
+		problems = append(problems, "weekday: cron's day rules aren't supported")
+	}
+
+	format := func(field calendarField, values []int) string {
+		text, ok := field.format(values)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: values must be between %d and %d", field.name, field.min, field.max))
+		}
+		return text
+	}
+
+	// Days counting back from the end of the month follow a "~".
+	dayField, days, daySeparator := calendarMonthDay, tg.day, "-"
+	if hasNegative(tg.day) {
+		dayField, days, daySeparator = calendarLastDay, []int{}, "~"
+		for _, d := range tg.day {
+			if d > 0 {
+				problems = append(problems, "day: days can't count from both ends of the month")
+				break
+			}
+			days = append(days, -d)
+		}
+		sort.Ints(days)
+	}
+
+	seconds := format(calendarSecond, tg.second)
+	if !(len(tg.millisecond) == 1 && tg.millisecond[0] == 0) {
+		if len(tg.millisecond) != 1 || tg.second == nil {
+			problems = append(problems, "millisecond: only listed seconds may have a fraction")
+		} else {
+			// Fractions only follow single seconds, not ranges.
+			parts := []string{}
+			for _, v := range tg.second {
+				parts = append(parts, fmt.Sprintf("%02d.%03d", v, tg.millisecond[0]))
+			}
+			seconds = strings.Join(parts, ",")
+		}
+	}
+
+	expr := format(calendarYear, tg.year) + "-" +
+		format(calendarMonth, tg.month) + daySeparator +
+		format(dayField, days) + " " +
+		format(calendarHour, tg.hour) + ":" +
+		format(calendarMinute, tg.minute) + ":" +
+		seconds
+
+	if tg.weekday != nil {
+		expr = formatCalendarWeekdays(tg.weekday) + " " + expr
+	}
+
+	if tg.location != time.Local {
+		if !namedLocation(tg.location) {
+			problems = append(problems, fmt.Sprintf("location: systemd needs a timezone name, not %q", tg.location.String()))
+		}
+		expr += " " + tg.location.String()
+	}
+
+	if len(problems) > 0 {
+		return "", fmt.Errorf("TimeGlob can't be represented as OnCalendar: %s", strings.Join(problems, "; "))
+	}
+	return expr, nil
+}
+
+func formatCalendarWeekdays(weekdays []int) string {
+	// Write TimeGlob weekdays as a list of weekdays and ranges, such as
+	// "Mon..Fri,Sun".
+
+	// calendarWeekdays starts on Monday, and TimeGlobs on Sunday.
+	days := []int{}
+	for _, d := range weekdays {
+		days = append(days, (d+6)%7)
+	}
+	sort.Ints(days)
+
+	name := func(d int) string {
+		return strings.ToUpper(calendarWeekdays[d][:1]) + calendarWeekdays[d][1:3]
+	}
+
+	parts := []string{}
+	for i := 0; i < len(days); {
+		j := i
+		for j+1 < len(days) && days[j+1] == days[j]+1 {
+			j++
+		}
+
+		if j-i >= 2 {
+			parts = append(parts, name(days[i])+".."+name(days[j]))
+		} else {
+			for k := i; k <= j; k++ {
+				parts = append(parts, name(days[k]))
+			}
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (f calendarField) format(values []int) (string, bool) {
+	// Write values as a component, using repetition or ranges where possible.
+	// Returns false if values can't be represented.
+
+	if values == nil {
+		return "*", true
+	}
+
+	for _, v := range values {
+		if v < f.min || v > f.max {
+			return "", false
+		}
+	}
+
+	value := func(v int) string {
+		return fmt.Sprintf("%0*d", f.width, v)
+	}
+
+	// Evenly spaced values which repeat until the end of the field, like
+	// "00/15".
+	if len(values) > 2 && f != calendarYear && f != calendarLastDay {
+		step := values[1] - values[0]
+		stepped := true
+		for i, v := range values {
+			stepped = stepped && v == values[0]+i*step
+		}
+		if stepped && step > 1 && values[len(values)-1]+step > f.max {
+			return fmt.Sprintf("%s/%d", value(values[0]), step), true
+		}
+	}
+
+	parts := []string{}
+	for i := 0; i < len(values); {
+		j := i
+		for j+1 < len(values) && values[j+1] == values[j]+1 {
+			j++
+		}
+
+		if j-i >= 2 {
+			parts = append(parts, value(values[i])+".."+value(values[j]))
+		} else {
+			for k := i; k <= j; k++ {
+				parts = append(parts, value(values[k]))
+			}
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ","), true
+}
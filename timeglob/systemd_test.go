@@ -0,0 +1,231 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func calendarMatchesGlob(c *check.C, expr string, glob string) {
+	// The OnCalendar expression should parse to the same TimeGlob as glob.
+
+	expected, err := Parse(glob)
+	c.Assert(err, check.IsNil)
+
+	tg, err := ParseOnCalendar(expr)
+	c.Check(err, check.IsNil, check.Commentf(expr))
+	c.Check(tg, check.DeepEquals, expected, check.Commentf(expr))
+}
+
+func (suite *MySuite) TestParseOnCalendar(c *check.C) {
+	calendarMatchesGlob(c, "*-*-* 09:00:00", "9:00")
+	calendarMatchesGlob(c, "*-*-* 09:00:00 Europe/Berlin", "9:00 Europe/Berlin")
+	calendarMatchesGlob(c, "2016-01-01 00:00:00 UTC", "2016/1/1 0:00 UTC")
+	calendarMatchesGlob(c, "*-01,07-01 12:30", "1,7/1 12:30")
+	calendarMatchesGlob(c, "03-15 00:00:30", "3/15 0:00:30")
+	calendarMatchesGlob(c, "*-*-01..03", "*/1,2,3 0:00")
+	calendarMatchesGlob(c, "09..17:00", "9,10,11,12,13,14,15,16,17:00")
+	calendarMatchesGlob(c, "*:00/15", "*:0,15,30,45")
+	calendarMatchesGlob(c, "*:05/20", "*:5,25,45")
+	calendarMatchesGlob(c, "08..18/4:00", "8,12,16:00")
+	calendarMatchesGlob(c, "*:*/30:*/30", "*:0,30:0,30")
+	calendarMatchesGlob(c, "2016..2018-*-* 00:00", "2016,2017,2018/*/* 0:00")
+
+	// Weekdays covering the whole week match every day.
+	calendarMatchesGlob(c, "Mon..Sun *-*-* 09:00", "9:00")
+	calendarMatchesGlob(c, "Sat,Sun,Monday..Friday 09:00 UTC", "9:00 UTC")
+
+	// Otherwise they become weekdays.
+	calendarMatchesGlob(c, "Mon..Fri *-*-* 09:00:00", "Mon-Fri 9:00")
+	calendarMatchesGlob(c, "Sat,Sun 10:00", "Sat,Sun 10:00")
+	calendarMatchesGlob(c, "Mon..Wed,Fri 09:00 Europe/Berlin", "Mon-Wed,Fri 9:00 Europe/Berlin")
+	calendarMatchesGlob(c, "Fri *-*-13", "Fri */13 0:00")
+
+	// Fractions of a second become milliseconds.
+	calendarMatchesGlob(c, "*:*:00.5", "*:*:0.5")
+	calendarMatchesGlob(c, "*-*-* 09:00:05.250", "9:00:05.25")
+	calendarMatchesGlob(c, "*:*:00.100,30.1", "*:*:0,30.1")
+	calendarMatchesGlob(c, "*:*:15.000", "*:*:15")
+}
+
+func (suite *MySuite) TestParseOnCalendarLastDay(c *check.C) {
+	// "~" counts days back from the end of the month.
+	days := map[string][]int{
+		"*-*~01":          {-1},
+		"*-02~03 00:00":   {-3},
+		"*-*~01,02":       {-2, -1},
+		"*-*~01..03":      {-3, -2, -1},
+		"*-05~07/1":       {-7, -6, -5, -4, -3, -2, -1},
+		"*-*~07/3 12:00":  {-7, -4, -1},
+		"Mon *-05~07/1":   {-7, -6, -5, -4, -3, -2, -1},
+		"2016-*~01 00:00": {-1},
+	}
+	for expr, expected := range days {
+		tg, err := ParseOnCalendar(expr)
+		c.Assert(err, check.IsNil, check.Commentf(expr))
+		c.Check(tg.day, check.DeepEquals, expected, check.Commentf(expr))
+	}
+
+	// The last Monday in May.
+	tg, err := ParseOnCalendar("Mon *-05~07/1 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.NextN(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), 3), check.DeepEquals, []time.Time{
+		time.Date(2016, 5, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 5, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2018, 5, 28, 0, 0, 0, 0, time.UTC),
+	})
+
+	// The third last day of February.
+	tg, err = ParseOnCalendar("*-02~03 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.NextN(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), 2), check.DeepEquals, []time.Time{
+		time.Date(2016, 2, 27, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 2, 26, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func (suite *MySuite) TestParseOnCalendarShorthands(c *check.C) {
+	calendarMatchesGlob(c, "minutely", "*:*:00")
+	calendarMatchesGlob(c, "hourly", "*:00")
+	calendarMatchesGlob(c, "daily", "0:00")
+	calendarMatchesGlob(c, "Daily UTC", "0:00 UTC")
+	calendarMatchesGlob(c, "monthly", "*/1 0:00")
+	calendarMatchesGlob(c, "yearly", "1/1 0:00")
+	calendarMatchesGlob(c, "annually", "1/1 0:00")
+	calendarMatchesGlob(c, "quarterly", "1,4,7,10/1 0:00")
+	calendarMatchesGlob(c, "semiannually", "1,7/1 0:00")
+	calendarMatchesGlob(c, "weekly", "Mon 0:00")
+	calendarMatchesGlob(c, "weekly UTC", "Mon 0:00 UTC")
+}
+
+func (suite *MySuite) TestParseOnCalendarUnsupported(c *check.C) {
+	bad := map[string]string{
+		"*:*:00.5,30.25":   "seconds with different fractions",
+		"*:*:00,30.25":     "seconds with different fractions",
+		"*:*:00.0001":      "fractions finer than a millisecond",
+		"*:*:00/0.5":       "fractional seconds",
+		"*:*:00.5..10.5":   "fractional seconds",
+		"2016/2-*-* 00:00": "years can't repeat forever",
+	}
+	for expr, reason := range bad {
+		_, err := ParseOnCalendar(expr)
+		c.Check(err, check.ErrorMatches, "OnCalendar expression can't be represented by a TimeGlob: .* \\("+reason+"\\)", check.Commentf(expr))
+	}
+}
+
+func (suite *MySuite) TestParseOnCalendarBad(c *check.C) {
+	exprs := []string{
+		"",
+		"fortnightly",
+		"09:00 Bad/Zone",
+		"*-*-* 24:00",
+		"*-13-* 00:00",
+		"*-*-32",
+		"*:60",
+		"1969-01-01",
+		"*-*-* 09",
+		"*-* *-* *:*",
+		"05..01:00",
+		"*:00/0",
+		"Fri..Mon 00:00",
+		"*-*~00",
+		"*-*~32",
+		"*-*~01~02",
+		"*-*-* 00:00 extra 00:00",
+	}
+
+	for _, expr := range exprs {
+		_, err := ParseOnCalendar(expr)
+		c.Check(err, check.ErrorMatches, "Not a valid OnCalendar expression.*", check.Commentf(expr))
+	}
+}
+
+func (suite *MySuite) TestToOnCalendar(c *check.C) {
+	cases := []struct {
+		glob     string
+		expected string
+	}{
+		{"9:00", "*-*-* 09:00:00"},
+		{"9:00 Europe/Berlin", "*-*-* 09:00:00 Europe/Berlin"},
+		{"2016/1/1 0:00 UTC", "2016-01-01 00:00:00 UTC"},
+		{"*:0,15,30,45", "*-*-* *:00/15:00"},
+		{"*:5,25,45", "*-*-* *:05/20:00"},
+		{"*/1,2,3,15 9,10,11,12:0", "*-*-01..03,15 09..12:00:00"},
+		{"1,7/1 0:00", "*-01,07-01 00:00:00"},
+		{"2016,2017,2018,2020/*/* 0:00", "2016..2018,2020-*-* 00:00:00"},
+		{"*:*:*", "*-*-* *:*:*"},
+		{"Mon-Fri 9:00", "Mon..Fri *-*-* 09:00:00"},
+		{"Sat,Sun 10:00 UTC", "Sat,Sun *-*-* 10:00:00 UTC"},
+		{"Mon,Wed,Fri-Sun 9:00", "Mon,Wed,Fri..Sun *-*-* 09:00:00"},
+		{"Fri */13 0:00", "Fri *-*-13 00:00:00"},
+		{"9:00:05.25", "*-*-* 09:00:05.250"},
+		{"*:*:0,30.5", "*-*-* *:*:00.500,30.500"},
+	}
+
+	for _, t := range cases {
+		tg, err := Parse(t.glob)
+		c.Assert(err, check.IsNil)
+
+		expr, err := tg.ToOnCalendar()
+		c.Check(err, check.IsNil, check.Commentf(t.glob))
+		c.Check(expr, check.Equals, t.expected, check.Commentf(t.glob))
+
+		// And back again.
+		back, err := ParseOnCalendar(expr)
+		c.Check(err, check.IsNil, check.Commentf(expr))
+		c.Check(back, check.DeepEquals, tg, check.Commentf(t.glob))
+	}
+}
+
+func (suite *MySuite) TestToOnCalendarLastDay(c *check.C) {
+	exprs := []string{
+		"*-*~01 00:00:00",
+		"*-02~03 00:00:00",
+		"Mon *-05~01..07 00:00:00",
+		"*-*~01,02,04 12:00:00 UTC",
+	}
+
+	for _, expr := range exprs {
+		tg, err := ParseOnCalendar(expr)
+		c.Assert(err, check.IsNil, check.Commentf(expr))
+
+		text, err := tg.ToOnCalendar()
+		c.Check(err, check.IsNil, check.Commentf(expr))
+		c.Check(text, check.Equals, expr)
+	}
+}
+
+func (suite *MySuite) TestToOnCalendarErrors(c *check.C) {
+	tg, err := Parse("*/* 25:00:*.5")
+	c.Assert(err, check.IsNil)
+
+	_, err = tg.ToOnCalendar()
+	c.Check(err, check.ErrorMatches,
+		"TimeGlob can't be represented as OnCalendar: millisecond: only listed seconds may have a fraction; hour: values must be between 0 and 23")
+
+	tg, err = Parse("*:*:0.0,5")
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToOnCalendar()
+	c.Check(err, check.ErrorMatches,
+		"TimeGlob can't be represented as OnCalendar: millisecond: only listed seconds may have a fraction")
+
+	tg, err = ParseCron("0 0 1,L * *", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToOnCalendar()
+	c.Check(err, check.ErrorMatches,
+		"TimeGlob can't be represented as OnCalendar: day: days can't count from both ends of the month")
+
+	tg, err = ParseCron("0 0 * * 5#3", CRON_STANDARD)
+	c.Assert(err, check.IsNil)
+	_, err = tg.ToOnCalendar()
+	c.Check(err, check.ErrorMatches,
+		"TimeGlob can't be represented as OnCalendar: weekday: cron's day rules aren't supported")
+
+	// systemd only looks up timezones by name.
+	for _, glob := range []string{"9:30 +05:30", "9:30 PDT", "9:30 UTC-8"} {
+		tg, err = Parse(glob)
+		c.Assert(err, check.IsNil)
+		_, err = tg.ToOnCalendar()
+		c.Check(err, check.ErrorMatches,
+			"TimeGlob can't be represented as OnCalendar: location: systemd needs a timezone name, not \".*\"", check.Commentf(glob))
+	}
+}
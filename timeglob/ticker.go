@@ -1,55 +1,542 @@
 package timeglob
 
 import (
+	"context"
+	"math/rand"
+	"sync"
 	"time"
 )
 
 type Ticker struct {
-	// Send time values to this on each tick.
+	// Send time values to this on each tick. Closed by Stop. nil if the Ticker
+	// was created with the Events option.
 	C <-chan time.Time
 
+	// Send a TickEvent to this on each tick. Closed by Stop. nil unless the
+	// Ticker was created with the Events option.
+	Events <-chan TickEvent
+
 	// What do we need to be able to do this?
-	tg       *TimeGlob
-	sendTick chan<- time.Time
-	timer    *time.Timer
+	lock        sync.Mutex
+	sched       Schedule
+	location    *time.Location
+	clock       Clock
+	missed      MissedTickPolicy
+	replayLimit int
+	sendTick    chan time.Time
+	sendEvent   chan TickEvent
+	timer       Timer
+	next        time.Time
+	sequence    uint64
+	dropped     int
+	stopped     bool
+	stopContext func() bool
+
+	// Used to notice changes to the wall clock while the timer is armed.
+	recheck     time.Duration
+	onClockJump func(expected, actual time.Time)
+	armedAt     time.Time
+	armedFor    time.Duration
+
+	// The random delay for t.next, with the Jitter option.
+	jitter time.Duration
+	delay  time.Duration
+
+	// If not nil, called on each tick instead of sending on C or Events. Used
+	// by Run. Called with the lock held.
+	notify func()
+
+	// Used to deliver queued ticks for REPLAY_MISSED.
+	queue      []TickEvent
+	wake       *sync.Cond
+	done       chan struct{}
+	delivering sync.WaitGroup
+
+	// Closed once Stop has finished.
+	closed chan struct{}
+}
+
+// Describes a single tick, for Tickers created with the Events option.
+type TickEvent struct {
+	// The glob match this tick is for.
+	Scheduled time.Time
+
+	// When the tick actually fired.
+	Fired time.Time
+
+	// How long after Scheduled the tick fired.
+	Late time.Duration
+
+	// Counts matches since the Ticker started, including dropped ones. The
+	// first match is 1.
+	Sequence uint64
+
+	// Matches since the previous event which were not delivered, because the
+	// tick fired too late, or Events was full.
+	Dropped int
+
+	// Matches before Scheduled which this event also stands for, with
+	// COALESCE_MISSED.
+	Coalesced int
+}
+
+// What a Ticker does with matches which can't be delivered on time, because
+// the tick fired late, or the receiver fell behind.
+type MissedTickPolicy int
+
+const (
+	// Only deliver the latest match. Others are dropped, and counted in
+	// TickEvent.Dropped.
+	DROP_MISSED MissedTickPolicy = iota
+
+	// Combine missed matches into a single tick for the latest match, which
+	// counts the others in TickEvent.Coalesced.
+	COALESCE_MISSED
+
+	// Deliver every missed match, in order. C receives the scheduled time of
+	// each match, instead of the time the tick fired.
+	REPLAY_MISSED
+)
+
+const (
+	// How long a Ticker sleeps before checking the wall clock again, unless
+	// TickerOptions.RecheckInterval is set.
+	DEFAULT_RECHECK_INTERVAL = time.Minute
+
+	// How far the wall clock can drift from the timers before it counts as a
+	// clock jump.
+	CLOCK_JUMP_TOLERANCE = time.Second
+)
+
+// Options which control a Ticker. The zero value gives the same results as
+// Ticker.
+type TickerOptions struct {
+	// Source of the current time, and timers. If nil, RealClock is used.
+	Clock Clock
+
+	// If not nil, the Ticker is stopped when this is cancelled.
+	Context context.Context
+
+	// Send TickEvent values on Events, instead of times on C.
+	Events bool
+
+	// How to handle missed matches.
+	Missed MissedTickPolicy
+
+	// With REPLAY_MISSED, the most ticks to queue for a slow receiver. The
+	// oldest are dropped beyond this. 0 means no limit.
+	ReplayLimit int
+
+	// The longest the Ticker sleeps before checking the wall clock again, so
+	// ticks still happen on time after a suspend, or the clock being changed.
+	// 0 means DEFAULT_RECHECK_INTERVAL. Negative values disable rechecks.
+	RecheckInterval time.Duration
+
+	// If not nil, called when the wall clock jumps forwards or backwards
+	// relative to the Ticker's timers, with the time the Ticker expected, and
+	// the actual time. Called from the Ticker's timer, so it must not block.
+	OnClockJump func(expected, actual time.Time)
+
+	// If set, delay each tick by a random duration less than this, so many
+	// Tickers with the same glob don't all fire at once. TickEvent.Scheduled
+	// is still the match itself.
+	Jitter time.Duration
 }
 
 func (tg *TimeGlob) Ticker() *Ticker {
-	sendTick := make(chan time.Time, 1)
+	return tg.TickerWithOptions(TickerOptions{})
+}
+
+func (tg *TimeGlob) TickerContext(ctx context.Context) *Ticker {
+	// Create a Ticker which is stopped when ctx is cancelled.
+	return tg.TickerWithOptions(TickerOptions{Context: ctx})
+}
+
+func (tg *TimeGlob) EventTicker() *Ticker {
+	// Create a Ticker which sends TickEvent values on Events.
+	return tg.TickerWithOptions(TickerOptions{Events: true})
+}
+
+func (tg *TimeGlob) TickerWithOptions(options TickerOptions) *Ticker {
+	return NewTicker(tg, options)
+}
+
+func NewTicker(s Schedule, options TickerOptions) *Ticker {
+	// Create a Ticker for any Schedule, such as a RepeatingInterval.
+	return newTicker(s, options, nil)
+}
+
+func newTicker(s Schedule, options TickerOptions, notify func()) *Ticker {
+	// Create a Ticker. If notify isn't nil, it's called for each tick, instead
+	// of sending on C or Events.
+
+	clock := options.Clock
+	if clock == nil {
+		clock = RealClock
+	}
 
 	result := &Ticker{
-		sendTick,
-		tg,
-		sendTick,
-		nil,
+		sched:       s,
+		location:    scheduleLocation(s),
+		clock:       clock,
+		missed:      options.Missed,
+		replayLimit: options.ReplayLimit,
+		recheck:     options.RecheckInterval,
+		onClockJump: options.OnClockJump,
+		jitter:      options.Jitter,
+		notify:      notify,
+		done:        make(chan struct{}),
+		closed:      make(chan struct{}),
+	}
+	result.wake = sync.NewCond(&result.lock)
+
+	if result.recheck == 0 {
+		result.recheck = DEFAULT_RECHECK_INTERVAL
+	}
+
+	if options.Events {
+		result.sendEvent = make(chan TickEvent, 1)
+		result.Events = result.sendEvent
+	} else {
+		result.sendTick = make(chan time.Time, 1)
+		result.C = result.sendTick
 	}
 
-	// Create the timer, now that there is a ticker to call tick() on.
-	now := time.Now().In(tg.location)
-	next := tg.Next(now)
-	if next != UNKNOWN {
-		result.timer = time.AfterFunc(next.Sub(now), result.tick)
+	if result.missed == REPLAY_MISSED {
+		result.delivering.Add(1)
+		go result.deliver()
 	}
+
+	// Hold the lock, so tick() and Stop() can't run until setup is finished.
+	result.lock.Lock()
+	defer result.lock.Unlock()
+
+	result.schedule(clock.Now())
+
+	if options.Context != nil {
+		result.stopContext = context.AfterFunc(options.Context, result.Stop)
+	}
+
 	return result
 }
 
+func (t *Ticker) schedule(now time.Time) {
+	// Find the next match after now, and arm the timer for it. Must hold the
+	// lock.
+
+	now = t.in(now)
+	t.next = t.sched.Next(now)
+	if t.next == UNKNOWN {
+		return
+	}
+
+	t.delay = 0
+	if t.jitter > 0 {
+		t.delay = time.Duration(rand.Int63n(int64(t.jitter)))
+	}
+
+	t.arm(now)
+}
+
+func (t *Ticker) arm(now time.Time) {
+	// Arm the timer for t.next plus any jitter, or for the recheck interval if
+	// that's sooner. Must hold the lock.
+
+	d := t.next.Add(t.delay).Sub(now)
+	if t.recheck > 0 && d > t.recheck {
+		d = t.recheck
+	}
+
+	t.armedAt = now
+	t.armedFor = d
+
+	if t.timer == nil {
+		t.timer = t.clock.AfterFunc(d, t.tick)
+	} else {
+		t.timer.Reset(d)
+	}
+}
+
 func (t *Ticker) tick() {
-	now := time.Now().In(t.tg.location)
+	// Called by the timer. Report clock jumps without holding the lock, so the
+	// callback can use the Ticker.
+	expected, actual, jumped := t.wakeUp()
+	if jumped && t.onClockJump != nil {
+		t.onClockJump(expected, actual)
+	}
+}
+
+func (t *Ticker) wakeUp() (expected, actual time.Time, jumped bool) {
+	// Check the wall clock, and send any ticks which are due. Returns the
+	// expected and actual wall clock times, and if they differ by more than
+	// CLOCK_JUMP_TOLERANCE.
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Stop may have raced with the timer firing.
+	if t.stopped {
+		return
+	}
+
+	now := t.in(t.clock.Now())
+
+	expected, jump := expectedWallClock(t.armedAt, now, t.armedFor)
+	actual = now
+	jumped = jump > CLOCK_JUMP_TOLERANCE || jump < -CLOCK_JUMP_TOLERANCE
+
+	if now.Before(t.next.Add(t.delay)) {
+		if jumped {
+			// The clock moved backwards, or not far enough forwards. Matches
+			// before t.next may be pending again.
+			t.schedule(now)
+		} else {
+			t.arm(now)
+		}
+		return
+	}
+
+	if t.notify != nil {
+		t.notify()
+	} else {
+		t.send(now)
+	}
+	t.schedule(now)
+	return
+}
+
+func (t *Ticker) in(now time.Time) time.Time {
+	// Convert now to the timezone of the schedule, if it has one.
+
+	if t.location == nil {
+		return now
+	}
+	return now.In(t.location)
+}
+
+func expectedWallClock(armedAt, now time.Time, armedFor time.Duration) (time.Time, time.Duration) {
+	// Find the wall clock time we expected to wake at, and how far the actual
+	// wall clock is past it. Times from time.Now carry a monotonic clock
+	// reading, which isn't affected by clock changes, so it's used when
+	// available. Otherwise we expect to wake exactly when the timer was due.
+
+	elapsed := armedFor
+	if hasMonotonic(armedAt) && hasMonotonic(now) {
+		elapsed = now.Sub(armedAt)
+	}
+
+	expected := armedAt.Round(0).Add(elapsed)
+	return expected, now.Round(0).Sub(expected)
+}
+
+func hasMonotonic(t time.Time) bool {
+	// Round(0) strips the monotonic clock reading, and nothing else.
+	return t != t.Round(0)
+}
+
+func (t *Ticker) send(now time.Time) {
+	// Send ticks for matches from t.next through now, following the missed
+	// tick policy. Must hold the lock.
+
+	scheduled := t.next
+
+	if t.missed == REPLAY_MISSED {
+		ScheduleBetweenFunc(t.sched, scheduled, now.Add(time.Nanosecond), func(match time.Time) bool {
+			t.sequence++
+			t.enqueue(t.event(match, now, 0))
+			return true
+		})
+		return
+	}
+
+	// If the tick fired late enough to pass more matches, only the latest
+	// is sent.
+	missed := 0
+	if latest := t.sched.Prev(now); latest != UNKNOWN && latest.After(scheduled) {
+		missed = ScheduleCount(t.sched, scheduled, latest)
+		scheduled = latest
+	}
+	t.sequence += uint64(missed) + 1
+
+	if t.missed == COALESCE_MISSED {
+		t.sendCoalesced(t.event(scheduled, now, missed))
+	} else {
+		t.sendLatest(t.event(scheduled, now, 0), missed)
+	}
+}
+
+func (t *Ticker) event(scheduled, now time.Time, coalesced int) TickEvent {
+	// Describe the tick for the latest sequence number. Must hold the lock.
+	return TickEvent{
+		Scheduled: scheduled.In(now.Location()),
+		Fired:     now,
+		Late:      now.Sub(scheduled),
+		Sequence:  t.sequence,
+		Coalesced: coalesced,
+	}
+}
+
+func (t *Ticker) sendLatest(event TickEvent, missed int) {
+	// Send a tick without blocking. If the channel already has a buffered
+	// value the tick is dropped. Must hold the lock.
+
+	event.Dropped = t.dropped + missed
+
+	if t.sendEvent == nil {
+		select {
+		case t.sendTick <- event.Fired:
+		default:
+		}
+		return
+	}
 
-	// Never block. The channel already has a buffered value.
 	select {
-	case t.sendTick <- now:
+	case t.sendEvent <- event:
+		t.dropped = 0
 	default:
+		t.dropped = event.Dropped + 1
 	}
+}
 
-	next := t.tg.Next(now)
-	if next != UNKNOWN {
-		t.timer.Reset(next.Sub(now))
+func (t *Ticker) sendCoalesced(event TickEvent) {
+	// Send a tick without blocking. If the channel already has a buffered
+	// value, it's replaced by a tick which stands for both. Must hold the
+	// lock.
+
+	if t.sendEvent == nil {
+		select {
+		case <-t.sendTick:
+		default:
+		}
+		t.sendTick <- event.Fired
+		return
 	}
+
+	select {
+	case previous := <-t.sendEvent:
+		event.Coalesced += previous.Coalesced + 1
+		event.Dropped += previous.Dropped
+	default:
+	}
+
+	// Only the Ticker sends, so there is always room now.
+	t.sendEvent <- event
+}
+
+func (t *Ticker) enqueue(event TickEvent) {
+	// Queue a tick for deliver(), dropping the oldest ticks beyond the replay
+	// limit. Must hold the lock.
+
+	t.queue = append(t.queue, event)
+
+	for t.replayLimit > 0 && len(t.queue) > t.replayLimit {
+		t.queue[1].Dropped += t.queue[0].Dropped + 1
+		t.queue = t.queue[1:]
+	}
+
+	t.wake.Broadcast()
+}
+
+func (t *Ticker) deliver() {
+	// Send queued ticks in order, waiting for the receiver as needed. Runs in
+	// its own goroutine until the Ticker is stopped.
+
+	defer t.delivering.Done()
+
+	for {
+		t.lock.Lock()
+		for len(t.queue) == 0 && !t.stopped {
+			t.wake.Wait()
+		}
+		if t.stopped {
+			t.lock.Unlock()
+			return
+		}
+		event := t.queue[0]
+		t.queue = t.queue[1:]
+		t.lock.Unlock()
+
+		if t.sendEvent != nil {
+			select {
+			case t.sendEvent <- event:
+			case <-t.done:
+				return
+			}
+		} else {
+			select {
+			case t.sendTick <- event.Scheduled:
+			case <-t.done:
+				return
+			}
+		}
+	}
+}
+
+func (t *Ticker) Reset(s Schedule) {
+	// Switch to ticking on matches of a different glob, or other Schedule.
+	// Any tick already waiting in C or Events is kept. Does nothing if the
+	// Ticker is stopped.
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.stopped {
+		return
+	}
+
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	t.sched = s
+	t.location = scheduleLocation(s)
+	t.schedule(t.clock.Now())
 }
 
 func (t *Ticker) Stop() {
+	// Stop the Ticker. Once Stop returns no more ticks will be sent, any tick
+	// waiting in C or Events is discarded, and they are closed. It's safe to
+	// call Stop more than once, or concurrently with ticks.
+
+	t.lock.Lock()
+	if t.stopped {
+		t.lock.Unlock()
+		<-t.closed
+		return
+	}
+	t.stopped = true
+
 	if t.timer != nil {
 		t.timer.Stop()
 	}
+
+	if t.stopContext != nil {
+		t.stopContext()
+	}
+
+	t.queue = nil
+	close(t.done)
+	t.wake.Broadcast()
+	t.lock.Unlock()
+
+	// Once deliver() exits, nothing else can send.
+	t.delivering.Wait()
+
+	if t.sendTick != nil {
+		select {
+		case <-t.sendTick:
+		default:
+		}
+		close(t.sendTick)
+	}
+
+	if t.sendEvent != nil {
+		select {
+		case <-t.sendEvent:
+		default:
+		}
+		close(t.sendEvent)
+	}
+
+	close(t.closed)
 }
@@ -1,11 +1,51 @@
-package timeglob
+package timeglob_test
 
 import (
+	"context"
+	"github.com/DonGar/go-timeglob/timeglob"
+	"github.com/DonGar/go-timeglob/timeglob/timeglobtest"
 	"gopkg.in/check.v1"
+	"sync"
+	"time"
 )
 
-func (suite *MySuite) TestTickerStartStop(c *check.C) {
-	tg, err := Parse("2012/11/25 19:37 America/New_York")
+// Ticker tests use timeglobtest, which imports timeglob, so they live in a
+// separate package with their own suite.
+type TickerSuite struct{}
+
+var _ = check.Suite(&TickerSuite{})
+
+func receive(c *check.C, ticker *timeglob.Ticker, expected time.Time) {
+	// Validate that exactly one tick is waiting, with the expected value.
+	select {
+	case tick := <-ticker.C:
+		c.Check(tick.Equal(expected), check.Equals, true, check.Commentf("%s != %s", tick, expected))
+	default:
+		c.Errorf("No tick, expected %s", expected)
+	}
+	receiveNone(c, ticker)
+}
+
+func receiveNone(c *check.C, ticker *timeglob.Ticker) {
+	select {
+	case tick := <-ticker.C:
+		c.Errorf("Unexpected tick %s", tick)
+	default:
+	}
+}
+
+func receiveClosed(c *check.C, ticker *timeglob.Ticker) {
+	// Validate that C is closed, without any tick waiting.
+	select {
+	case tick, ok := <-ticker.C:
+		c.Check(ok, check.Equals, false, check.Commentf("Unexpected tick %s", tick))
+	case <-time.After(10 * time.Second):
+		c.Error("C was not closed")
+	}
+}
+
+func (suite *TickerSuite) TestTickerStartStop(c *check.C) {
+	tg, err := timeglob.Parse("2012/11/25 19:37 America/New_York")
 	c.Assert(err, check.IsNil)
 
 	// Create a ticker, and then stop it right away.
@@ -13,5 +53,502 @@ func (suite *MySuite) TestTickerStartStop(c *check.C) {
 	ticker.Stop()
 }
 
-// Proper tests require a way to mock out time.Now(). Delaying them until
-// I wrap an interface around it.
+func (suite *TickerSuite) TestTickerFakeClock(c *check.C) {
+	tg, err := timeglob.Parse("*:0,30 UTC")
+	c.Assert(err, check.IsNil)
+
+	start := time.Date(2016, 1, 1, 0, 10, 0, 0, time.UTC)
+	clock := timeglobtest.NewFakeClock(start)
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock})
+	c.Check(clock.Pending(), check.Equals, 1)
+
+	clock.Advance(19 * time.Minute)
+	receiveNone(c, ticker)
+
+	clock.Advance(time.Minute)
+	receive(c, ticker, time.Date(2016, 1, 1, 0, 30, 0, 0, time.UTC))
+
+	clock.Advance(30 * time.Minute)
+	receive(c, ticker, time.Date(2016, 1, 1, 1, 0, 0, 0, time.UTC))
+
+	// Ticks are dropped if the channel is full.
+	clock.Advance(time.Hour)
+	receive(c, ticker, time.Date(2016, 1, 1, 1, 30, 0, 0, time.UTC))
+
+	ticker.Stop()
+	c.Check(clock.Pending(), check.Equals, 0)
+
+	clock.Advance(time.Hour)
+	receiveClosed(c, ticker)
+}
+
+func (suite *TickerSuite) TestTickerDsl(c *check.C) {
+	tg, err := timeglob.Parse("*:12 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	loc, err := time.LoadLocation("America/New_York")
+	c.Assert(err, check.IsNil)
+
+	start := time.Date(2016, 11, 6, 0, 0, 0, 0, loc)
+	clock := timeglobtest.NewFakeClock(start)
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock})
+	defer ticker.Stop()
+
+	// 1:12 AM happens twice.
+	first := time.Date(2016, 11, 6, 1, 12, 0, 0, loc)
+	expected := []time.Time{
+		time.Date(2016, 11, 6, 0, 12, 0, 0, loc),
+		first,
+		first.Add(time.Hour),
+		time.Date(2016, 11, 6, 2, 12, 0, 0, loc),
+	}
+
+	for _, e := range expected {
+		clock.AdvanceTo(e)
+		receive(c, ticker, e)
+	}
+}
+
+func (suite *TickerSuite) TestTickerNoMatch(c *check.C) {
+	tg, err := timeglob.Parse("2012/11/25 19:37 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	// No future matches means no timer.
+	clock := timeglobtest.NewFakeClock(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock})
+	c.Check(clock.Pending(), check.Equals, 0)
+	ticker.Stop()
+}
+
+func (suite *TickerSuite) TestTickerStop(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(time.Date(2016, 1, 1, 0, 30, 0, 0, time.UTC))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock})
+
+	// Leave a tick unread. Stop discards it, and closes C.
+	clock.Advance(time.Hour)
+	ticker.Stop()
+	receiveClosed(c, ticker)
+	c.Check(clock.Pending(), check.Equals, 0)
+
+	// Stop and Reset after Stop do nothing.
+	ticker.Stop()
+	ticker.Reset(tg)
+	c.Check(clock.Pending(), check.Equals, 0)
+}
+
+func (suite *TickerSuite) TestTickerContext(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticker := tg.TickerContext(ctx)
+
+	cancel()
+	receiveClosed(c, ticker)
+
+	// Already cancelled contexts stop right away.
+	clock := timeglobtest.NewFakeClock(time.Date(2016, 1, 1, 0, 30, 0, 0, time.UTC))
+	ticker = tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock, Context: ctx})
+	receiveClosed(c, ticker)
+	c.Check(clock.Pending(), check.Equals, 0)
+}
+
+func (suite *TickerSuite) TestTickerReset(c *check.C) {
+	hourly, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	halfHourly, err := timeglob.Parse("*:30 UTC")
+	c.Assert(err, check.IsNil)
+
+	never, err := timeglob.Parse("2012/1/1 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(time.Date(2016, 1, 1, 0, 10, 0, 0, time.UTC))
+	ticker := hourly.TickerWithOptions(timeglob.TickerOptions{Clock: clock})
+	defer ticker.Stop()
+
+	ticker.Reset(halfHourly)
+	c.Check(clock.Pending(), check.Equals, 1)
+
+	clock.Advance(time.Hour)
+	receive(c, ticker, time.Date(2016, 1, 1, 0, 30, 0, 0, time.UTC))
+
+	// Switching to a glob with no matches disarms the timer, but it can be
+	// switched back.
+	ticker.Reset(never)
+	c.Check(clock.Pending(), check.Equals, 0)
+
+	ticker.Reset(hourly)
+	clock.Advance(time.Hour)
+	receive(c, ticker, time.Date(2016, 1, 1, 2, 0, 0, 0, time.UTC))
+}
+
+func (suite *TickerSuite) TestTickerConcurrent(c *check.C) {
+	// Run with -race to validate locking.
+	tg, err := timeglob.Parse("*:*:* UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock})
+
+	var wait sync.WaitGroup
+	wait.Add(3)
+
+	go func() {
+		defer wait.Done()
+		for i := 0; i < 100; i++ {
+			clock.Advance(time.Second)
+		}
+	}()
+
+	go func() {
+		defer wait.Done()
+		for i := 0; i < 10; i++ {
+			ticker.Reset(tg)
+		}
+		ticker.Stop()
+	}()
+
+	go func() {
+		defer wait.Done()
+		for range ticker.C {
+		}
+	}()
+
+	wait.Wait()
+	receiveClosed(c, ticker)
+}
+
+func checkEvent(c *check.C, event, expected timeglob.TickEvent) {
+	c.Check(event.Scheduled.Equal(expected.Scheduled), check.Equals, true,
+		check.Commentf("%s != %s", event.Scheduled, expected.Scheduled))
+	c.Check(event.Fired.Equal(expected.Fired), check.Equals, true,
+		check.Commentf("%s != %s", event.Fired, expected.Fired))
+	c.Check(event.Late, check.Equals, expected.Late)
+	c.Check(event.Sequence, check.Equals, expected.Sequence)
+	c.Check(event.Dropped, check.Equals, expected.Dropped)
+	c.Check(event.Coalesced, check.Equals, expected.Coalesced)
+}
+
+func receiveEvent(c *check.C, ticker *timeglob.Ticker, expected timeglob.TickEvent) {
+	// Validate that exactly one event is waiting, with the expected value.
+	select {
+	case event := <-ticker.Events:
+		checkEvent(c, event, expected)
+	default:
+		c.Errorf("No event, expected %v", expected)
+	}
+
+	select {
+	case event := <-ticker.Events:
+		c.Errorf("Unexpected event %v", event)
+	default:
+	}
+}
+
+func (suite *TickerSuite) TestTickerEvents(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0).Add(time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{Clock: clock, Events: true})
+	c.Check(ticker.C, check.IsNil)
+
+	clock.AdvanceTo(hour(1))
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: hour(1), Fired: hour(1), Sequence: 1})
+
+	// Late ticks report how late they are.
+	clock.Set(hour(2).Add(5 * time.Second))
+	clock.Advance(0)
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: hour(2), Fired: hour(2).Add(5 * time.Second), Late: 5 * time.Second, Sequence: 2})
+
+	// Fire late enough to pass two more matches. Only the latest is sent.
+	clock.Set(hour(5).Add(time.Minute))
+	clock.Advance(0)
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: hour(5), Fired: hour(5).Add(time.Minute), Late: time.Minute, Sequence: 5, Dropped: 2})
+
+	// Drop ticks because Events is full.
+	clock.AdvanceTo(hour(6))
+	clock.AdvanceTo(hour(7))
+	clock.AdvanceTo(hour(8))
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: hour(6), Fired: hour(6), Sequence: 6})
+
+	clock.AdvanceTo(hour(9))
+	receiveEvent(c, ticker, timeglob.TickEvent{Scheduled: hour(9), Fired: hour(9), Sequence: 9, Dropped: 2})
+
+	ticker.Stop()
+	_, ok := <-ticker.Events
+	c.Check(ok, check.Equals, false)
+}
+
+func (suite *TickerSuite) TestEventTicker(c *check.C) {
+	tg, err := timeglob.Parse("2012/11/25 19:37 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	ticker := tg.EventTicker()
+	c.Check(ticker.C, check.IsNil)
+	c.Check(ticker.Events, check.NotNil)
+	ticker.Stop()
+}
+
+func receiveEventWait(c *check.C, ticker *timeglob.Ticker, expected timeglob.TickEvent) {
+	// Wait for the next event, which may be sent from another goroutine.
+	select {
+	case event := <-ticker.Events:
+		checkEvent(c, event, expected)
+	case <-time.After(10 * time.Second):
+		c.Errorf("No event, expected %v", expected)
+	}
+}
+
+func hour(h int) time.Time {
+	return time.Date(2016, 1, 1, h, 0, 0, 0, time.UTC)
+}
+
+func (suite *TickerSuite) TestTickerCoalesce(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0).Add(time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:  clock,
+		Events: true,
+		Missed: timeglob.COALESCE_MISSED,
+	})
+	defer ticker.Stop()
+
+	// Fire late enough to pass two more matches.
+	clock.Set(hour(3).Add(time.Minute))
+	clock.Advance(0)
+	receiveEvent(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(3), Fired: hour(3).Add(time.Minute), Late: time.Minute,
+		Sequence: 3, Coalesced: 2})
+
+	// The receiver falls behind.
+	clock.AdvanceTo(hour(4))
+	clock.AdvanceTo(hour(5))
+	clock.AdvanceTo(hour(6))
+	receiveEvent(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(6), Fired: hour(6), Sequence: 6, Coalesced: 2})
+
+	// Both at once.
+	clock.AdvanceTo(hour(7))
+	clock.Set(hour(9))
+	clock.Advance(0)
+	receiveEvent(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(9), Fired: hour(9), Sequence: 9, Coalesced: 2})
+}
+
+func (suite *TickerSuite) TestTickerCoalesceTimes(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0).Add(time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:  clock,
+		Missed: timeglob.COALESCE_MISSED,
+	})
+	defer ticker.Stop()
+
+	// C keeps the latest time.
+	clock.AdvanceTo(hour(1))
+	clock.AdvanceTo(hour(2))
+	receive(c, ticker, hour(2))
+}
+
+func (suite *TickerSuite) TestTickerReplay(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0).Add(time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:  clock,
+		Events: true,
+		Missed: timeglob.REPLAY_MISSED,
+	})
+	defer ticker.Stop()
+
+	// Fire late enough to pass two more matches. All are sent.
+	fired := hour(3).Add(time.Minute)
+	clock.Set(fired)
+	clock.Advance(0)
+	receiveEventWait(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(1), Fired: fired, Late: 2*time.Hour + time.Minute, Sequence: 1})
+	receiveEventWait(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(2), Fired: fired, Late: time.Hour + time.Minute, Sequence: 2})
+	receiveEventWait(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(3), Fired: fired, Late: time.Minute, Sequence: 3})
+
+	// The receiver falls behind.
+	for h := 4; h <= 8; h++ {
+		clock.AdvanceTo(hour(h))
+	}
+	for h := 4; h <= 8; h++ {
+		receiveEventWait(c, ticker, timeglob.TickEvent{
+			Scheduled: hour(h), Fired: hour(h), Sequence: uint64(h)})
+	}
+}
+
+func (suite *TickerSuite) TestTickerReplayLimit(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0).Add(time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:       clock,
+		Events:      true,
+		Missed:      timeglob.REPLAY_MISSED,
+		ReplayLimit: 2,
+	})
+	defer ticker.Stop()
+
+	// Only the last two matches are queued.
+	clock.Set(hour(5))
+	clock.Advance(0)
+	receiveEventWait(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(4), Fired: hour(5), Late: time.Hour, Sequence: 4, Dropped: 3})
+	receiveEventWait(c, ticker, timeglob.TickEvent{
+		Scheduled: hour(5), Fired: hour(5), Sequence: 5})
+}
+
+func (suite *TickerSuite) TestTickerReplayTimes(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0).Add(time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:  clock,
+		Missed: timeglob.REPLAY_MISSED,
+	})
+
+	// C receives the scheduled times.
+	clock.Set(hour(3).Add(time.Minute))
+	clock.Advance(0)
+	for h := 1; h <= 3; h++ {
+		select {
+		case tick := <-ticker.C:
+			c.Check(tick.Equal(hour(h)), check.Equals, true)
+		case <-time.After(10 * time.Second):
+			c.Errorf("No tick, expected %s", hour(h))
+		}
+	}
+
+	// Stop with ticks still queued.
+	clock.Set(hour(6))
+	clock.Advance(0)
+	ticker.Stop()
+	for range ticker.C {
+	}
+}
+
+type clockJump struct {
+	expected, actual time.Time
+}
+
+func (suite *TickerSuite) TestTickerJumpForward(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	jumps := []clockJump{}
+	clock := timeglobtest.NewFakeClock(hour(0).Add(30 * time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock: clock,
+		OnClockJump: func(expected, actual time.Time) {
+			jumps = append(jumps, clockJump{expected, actual})
+		},
+	})
+	defer ticker.Stop()
+
+	// Rechecking the clock isn't a jump.
+	clock.Advance(10 * time.Minute)
+	receiveNone(c, ticker)
+	c.Check(jumps, check.DeepEquals, []clockJump{})
+
+	// A suspend passes 1:00 and 2:00. The tick happens within the recheck
+	// interval, instead of at 2:40 as the timer originally expected.
+	clock.Jump(2 * time.Hour)
+	clock.Advance(time.Minute)
+	receive(c, ticker, hour(2).Add(41*time.Minute))
+	c.Check(jumps, check.DeepEquals, []clockJump{
+		{hour(0).Add(41 * time.Minute), hour(2).Add(41 * time.Minute)},
+	})
+
+	clock.Advance(19 * time.Minute)
+	receive(c, ticker, hour(3))
+	c.Check(len(jumps), check.Equals, 1)
+}
+
+func (suite *TickerSuite) TestTickerJumpBackward(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	jumps := []clockJump{}
+	clock := timeglobtest.NewFakeClock(hour(3).Add(30 * time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock: clock,
+		OnClockJump: func(expected, actual time.Time) {
+			jumps = append(jumps, clockJump{expected, actual})
+		},
+	})
+	defer ticker.Stop()
+
+	// The clock is set back 2 hours, so the next tick is at 2:00, not 4:00.
+	clock.Jump(-2 * time.Hour)
+	clock.Advance(time.Minute)
+	c.Check(jumps, check.DeepEquals, []clockJump{
+		{hour(3).Add(31 * time.Minute), hour(1).Add(31 * time.Minute)},
+	})
+
+	clock.Advance(29 * time.Minute)
+	receive(c, ticker, hour(2))
+}
+
+func (suite *TickerSuite) TestTickerNoRecheck(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	// Without rechecks, the timer only fires after its original duration.
+	clock := timeglobtest.NewFakeClock(hour(0).Add(30 * time.Minute))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:           clock,
+		RecheckInterval: -1,
+	})
+	defer ticker.Stop()
+
+	clock.Jump(2 * time.Hour)
+	clock.Advance(29 * time.Minute)
+	receiveNone(c, ticker)
+
+	clock.Advance(time.Minute)
+	receive(c, ticker, hour(3))
+}
+
+func (suite *TickerSuite) TestTickerJitter(c *check.C) {
+	tg, err := timeglob.Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+
+	clock := timeglobtest.NewFakeClock(hour(0))
+	ticker := tg.TickerWithOptions(timeglob.TickerOptions{
+		Clock:  clock,
+		Events: true,
+		Jitter: 10 * time.Minute,
+	})
+	defer ticker.Stop()
+
+	// Each tick is somewhere in the 10 minutes after its match.
+	for h := 1; h <= 20; h++ {
+		clock.AdvanceTo(hour(h).Add(10 * time.Minute))
+
+		select {
+		case event := <-ticker.Events:
+			c.Check(event.Scheduled, check.Equals, hour(h))
+			c.Check(event.Late >= 0 && event.Late < 10*time.Minute, check.Equals, true,
+				check.Commentf("Late %s", event.Late))
+		default:
+			c.Fatalf("No tick for hour %d", h)
+		}
+	}
+}
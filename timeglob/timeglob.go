@@ -7,20 +7,28 @@ import (
 // Used when no valid matching time exists.
 var UNKNOWN time.Time
 
-// nil values are used to represent wildcards.
+// nil values are used to represent wildcards. Negative days count back from
+// the end of the month, so -1 is the last day. Weekdays are 0 for Sunday
+// through 6 for Saturday.
 type TimeGlob struct {
-	year     []int
-	month    []int
-	day      []int
-	hour     []int
-	minute   []int
-	second   []int
-	location *time.Location
+	year        []int
+	month       []int
+	day         []int
+	weekday     []int
+	hour        []int
+	minute      []int
+	second      []int
+	millisecond []int
+	location    *time.Location
+
+	// Day rules from cron and other formats which can't be written as lists,
+	// or nil.
+	rules *dayRules
 }
 
 func new() TimeGlob {
 	return TimeGlob{
-		nil, nil, nil,
-		[]int{0}, []int{0}, []int{0},
-		time.Local}
+		nil, nil, nil, nil,
+		[]int{0}, []int{0}, []int{0}, []int{0},
+		time.Local, nil}
 }
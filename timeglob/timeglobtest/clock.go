@@ -0,0 +1,166 @@
+// Package timeglobtest provides helpers for testing code which uses timeglob.
+package timeglobtest
+
+import (
+	"github.com/DonGar/go-timeglob/timeglob"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A timeglob.Clock which only moves when told to. Timers fire synchronously
+// inside of Advance or AdvanceTo, in order, with the clock set to the moment
+// each timer was due.
+type FakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock  *FakeClock
+	when   time.Time
+	f      func()
+	active bool
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) timeglob.Timer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	timer := &fakeTimer{c, c.now.Add(d), f, true}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	// Move the clock forward by d, firing any timers which come due.
+
+	c.lock.Lock()
+	target := c.now.Add(d)
+	c.lock.Unlock()
+
+	c.AdvanceTo(target)
+}
+
+func (c *FakeClock) AdvanceTo(target time.Time) {
+	// Move the clock forward to target, firing any timers which come due. Does
+	// nothing if target is in the past.
+
+	for {
+		c.lock.Lock()
+		timer := c.nextTimer()
+		if timer == nil || timer.when.After(target) {
+			if c.now.Before(target) {
+				c.now = target
+			}
+			c.lock.Unlock()
+			return
+		}
+
+		if c.now.Before(timer.when) {
+			c.now = timer.when
+		}
+		timer.active = false
+		c.lock.Unlock()
+
+		// Call without the lock, so the timer function can use the clock.
+		timer.f()
+	}
+}
+
+func (c *FakeClock) Set(now time.Time) {
+	// Set the clock to any time, forwards or backwards, without firing timers.
+	// This simulates a process which was paused, or a clock being changed.
+	// Timers which are overdue fire on the next Advance or AdvanceTo.
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+}
+
+func (c *FakeClock) Jump(d time.Duration) {
+	// Move the wall clock by d, forwards or backwards, without affecting
+	// timers. This simulates an NTP step, or a machine which was suspended.
+	// Timers still fire after the same remaining duration, as real timers are
+	// based on the monotonic clock.
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, timer := range c.timers {
+		timer.when = timer.when.Add(d)
+	}
+}
+
+func (c *FakeClock) Pending() int {
+	// Return the number of timers which have not yet fired or been stopped.
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.removeInactive()
+	return len(c.timers)
+}
+
+func (c *FakeClock) nextTimer() *fakeTimer {
+	// Return the earliest active timer, or nil. Must hold the lock.
+
+	c.removeInactive()
+	if len(c.timers) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].when.Before(c.timers[j].when)
+	})
+	return c.timers[0]
+}
+
+func (c *FakeClock) removeInactive() {
+	// Forget timers which have fired or been stopped. Must hold the lock.
+
+	active := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.active {
+			active = append(active, timer)
+		}
+	}
+	c.timers = active
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.active = false
+	return wasActive
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.when = t.clock.now.Add(d)
+	if !wasActive {
+		t.clock.removeInactive()
+		t.active = true
+		t.clock.timers = append(t.clock.timers, t)
+	}
+	return wasActive
+}
@@ -0,0 +1,118 @@
+package timeglobtest
+
+import (
+	"github.com/DonGar/go-timeglob/timeglob"
+	"gopkg.in/check.v1"
+	"testing"
+	"time"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MySuite struct{}
+
+var _ = check.Suite(&MySuite{})
+
+var start = time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (suite *MySuite) TestFakeClockNow(c *check.C) {
+	clock := NewFakeClock(start)
+	c.Check(clock.Now(), check.Equals, start)
+
+	clock.Advance(time.Hour)
+	c.Check(clock.Now(), check.Equals, start.Add(time.Hour))
+
+	// Never moves backwards.
+	clock.AdvanceTo(start)
+	c.Check(clock.Now(), check.Equals, start.Add(time.Hour))
+}
+
+func (suite *MySuite) TestFakeClockTimers(c *check.C) {
+	clock := NewFakeClock(start)
+	fired := []string{}
+
+	record := func(name string) func() {
+		return func() {
+			fired = append(fired, name+" "+clock.Now().Sub(start).String())
+		}
+	}
+
+	clock.AfterFunc(3*time.Second, record("a"))
+	clock.AfterFunc(1*time.Second, record("b"))
+	stopped := clock.AfterFunc(2*time.Second, record("c"))
+	c.Check(clock.Pending(), check.Equals, 3)
+
+	c.Check(stopped.Stop(), check.Equals, true)
+	c.Check(stopped.Stop(), check.Equals, false)
+	c.Check(clock.Pending(), check.Equals, 2)
+
+	// Timers fire in order, at the time they were due.
+	clock.Advance(5 * time.Second)
+	c.Check(fired, check.DeepEquals, []string{"b 1s", "a 3s"})
+	c.Check(clock.Now(), check.Equals, start.Add(5*time.Second))
+	c.Check(clock.Pending(), check.Equals, 0)
+
+	// Reset restarts a stopped or fired timer, relative to now.
+	c.Check(stopped.Reset(time.Second), check.Equals, false)
+	c.Check(stopped.Reset(2*time.Second), check.Equals, true)
+	c.Check(clock.Pending(), check.Equals, 1)
+
+	clock.Advance(time.Second)
+	c.Check(len(fired), check.Equals, 2)
+	clock.Advance(time.Second)
+	c.Check(fired, check.DeepEquals, []string{"b 1s", "a 3s", "c 7s"})
+}
+
+func (suite *MySuite) TestFakeClockRearm(c *check.C) {
+	// Timers can reset themselves, and fire more than once in one Advance.
+	clock := NewFakeClock(start)
+	count := 0
+
+	var timer timeglob.Timer
+	timer = clock.AfterFunc(time.Second, func() {
+		count++
+		timer.Reset(time.Second)
+	})
+
+	clock.Advance(10 * time.Second)
+	c.Check(count, check.Equals, 10)
+	c.Check(clock.Pending(), check.Equals, 1)
+}
+
+func (suite *MySuite) TestFakeClockSet(c *check.C) {
+	clock := NewFakeClock(start)
+	fired := []time.Time{}
+
+	clock.AfterFunc(time.Second, func() { fired = append(fired, clock.Now()) })
+
+	// Setting the time doesn't fire timers.
+	clock.Set(start.Add(time.Hour))
+	c.Check(clock.Now(), check.Equals, start.Add(time.Hour))
+	c.Check(len(fired), check.Equals, 0)
+
+	// Overdue timers fire late on the next Advance.
+	clock.Advance(0)
+	c.Check(fired, check.DeepEquals, []time.Time{start.Add(time.Hour)})
+
+	// Time can move backwards.
+	clock.Set(start)
+	c.Check(clock.Now(), check.Equals, start)
+}
+
+func (suite *MySuite) TestFakeClockJump(c *check.C) {
+	clock := NewFakeClock(start)
+	fired := []time.Time{}
+
+	clock.AfterFunc(time.Minute, func() { fired = append(fired, clock.Now()) })
+
+	// Jumps move the wall clock, but timers keep their remaining duration.
+	clock.Jump(-time.Hour)
+	c.Check(clock.Now(), check.Equals, start.Add(-time.Hour))
+
+	clock.Advance(59 * time.Second)
+	c.Check(fired, check.DeepEquals, []time.Time{})
+
+	clock.Advance(time.Second)
+	c.Check(fired, check.DeepEquals, []time.Time{start.Add(-time.Hour + time.Minute)})
+}
@@ -0,0 +1,142 @@
+package timeglob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Separates the globs to exclude in ParseSchedule.
+var exceptRegexp = regexp.MustCompile(`\s*\bexcept\b\s*`)
+
+// A Schedule which matches whenever any of its members match. Members can be
+// in different timezones, and times matched by more than one member only
+// match once.
+type Union struct {
+	schedules []Schedule
+}
+
+func NewUnion(schedules ...Schedule) *Union {
+	// Create a Schedule which matches each time any of schedules match.
+	return &Union{append([]Schedule{}, schedules...)}
+}
+
+func ParseSchedule(text string) (Schedule, error) {
+	return ParseScheduleWithOptions(text, ParseOptions{})
+}
+
+func ParseScheduleWithOptions(text string, options ParseOptions) (Schedule, error) {
+	// Parse globs separated by "|", such as "*/25 9:00 | 12/25 0:00 UTC",
+	// into a Union. Each glob has its own timezone. A single glob is returned
+	// as a TimeGlob.
+	//
+	// Globs after "except" are excluded, so "*:0 except 12/24-26 *:*"
+	// matches every hour, except during December 24th to 26th. "except"
+	// applies to everything before it, so "a | b except c | d" is
+	// (a | b) except (c | d).
+	//
+	// Parse can't accept these, since it returns a *TimeGlob, whose callers
+	// rely on methods like ToCron and Nth which a Union or Except can't
+	// provide, so it points at ParseSchedule instead.
+
+	sections := exceptRegexp.Split(text, -1)
+
+	schedules := []Schedule{}
+	for _, section := range sections {
+		s, err := parseUnion(section, text, options)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	if len(schedules) == 1 {
+		return schedules[0], nil
+	}
+
+	exclude := schedules[1]
+	if len(schedules) > 2 {
+		exclude = NewUnion(schedules[1:]...)
+	}
+	return NewExcept(schedules[0], exclude), nil
+}
+
+func parseUnion(section, text string, options ParseOptions) (Schedule, error) {
+	// Parse globs or named schedules separated by "|".
+
+	schedules := []Schedule{}
+	for _, part := range strings.Split(section, "|") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("Not a valid schedule: %s (empty glob)", text)
+		}
+
+		if named, ok := options.Schedules[part]; ok {
+			schedules = append(schedules, named)
+			continue
+		}
+
+		tg, err := ParseWithOptions(part, options)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, tg)
+	}
+
+	if len(schedules) == 1 {
+		return schedules[0], nil
+	}
+	return NewUnion(schedules...), nil
+}
+
+func (u *Union) Schedules() []Schedule {
+	return append([]Schedule{}, u.schedules...)
+}
+
+func (u *Union) Next(now time.Time) time.Time {
+	// Find the earliest match of any member after now. Returns UNKNOWN if
+	// there isn't one. The result is in the same timezone as now.
+
+	result := UNKNOWN
+	for _, s := range u.schedules {
+		t := s.Next(now)
+		if t != UNKNOWN && (result == UNKNOWN || t.Before(result)) {
+			result = t
+		}
+	}
+
+	if result != UNKNOWN {
+		result = result.In(now.Location())
+	}
+	return result
+}
+
+func (u *Union) Prev(now time.Time) time.Time {
+	// Find the latest match of any member before, or equal to now. Returns
+	// UNKNOWN if there isn't one. The result is in the same timezone as now.
+
+	result := UNKNOWN
+	for _, s := range u.schedules {
+		t := s.Prev(now)
+		if t != UNKNOWN && (result == UNKNOWN || t.After(result)) {
+			result = t
+		}
+	}
+
+	if result != UNKNOWN {
+		result = result.In(now.Location())
+	}
+	return result
+}
+
+func (u *Union) Matches(t time.Time) bool {
+	// Does any member match t?
+
+	for _, s := range u.schedules {
+		if ScheduleMatches(s, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"sort"
+	"time"
+)
+
+func (suite *MySuite) TestParseSchedule(c *check.C) {
+	// A single glob is just a TimeGlob.
+	s, err := ParseSchedule("*:0 UTC")
+	c.Assert(err, check.IsNil)
+	expected, err := Parse("*:0 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(s, check.DeepEquals, expected)
+
+	s, err = ParseSchedule("*/1 9:00 UTC | 12/25 0:00 UTC|2016/1/1 12:30 UTC")
+	c.Assert(err, check.IsNil)
+	u, ok := s.(*Union)
+	c.Assert(ok, check.Equals, true)
+	c.Check(u.Schedules(), check.HasLen, 3)
+
+	for _, text := range []string{"*:0 UTC |", "| *:0 UTC", "*:0 UTC || 9:00 UTC", ""} {
+		_, err := ParseSchedule(text)
+		c.Check(err, check.ErrorMatches, "Not a valid schedule: .* \\(empty glob\\)", check.Commentf(text))
+	}
+
+	_, err = ParseSchedule("*:0 UTC | Funday 9:00")
+	c.Check(err, check.ErrorMatches, "Not a valid TimeGlob: Funday 9:00")
+
+	// Parse returns a single TimeGlob, and points at ParseSchedule.
+	_, err = Parse("Mon-Fri 9:00 | Sat 11:00")
+	c.Check(err, check.ErrorMatches, "Not a valid TimeGlob: .* \\(use ParseSchedule for \"\\|\" and \"except\"\\)")
+	_, err = Parse("*:0 except 12/25 *:*")
+	c.Check(err, check.ErrorMatches, "Not a valid TimeGlob: .* \\(use ParseSchedule for \"\\|\" and \"except\"\\)")
+}
+
+func (suite *MySuite) TestUnionWeekdays(c *check.C) {
+	// Weekdays at 9:00 and Saturdays at 11:00 in Local, and Christmas in UTC.
+	s, err := ParseSchedule("Mon-Fri 9:00 | Sat 11:00 | 12/25 0:00 UTC")
+	c.Assert(err, check.IsNil)
+	u, ok := s.(*Union)
+	c.Assert(ok, check.Equals, true)
+	c.Check(u.Schedules(), check.HasLen, 3)
+
+	local := func(day, hour int) time.Time {
+		return time.Date(2016, 12, day, hour, 0, 0, 0, time.Local)
+	}
+
+	// December 19th 2016 is a Monday. Christmas is a Sunday, so only the UTC
+	// glob matches it, wherever it falls in Local.
+	christmas := time.Date(2016, 12, 25, 0, 0, 0, 0, time.UTC).In(time.Local)
+	expected := []time.Time{
+		local(19, 9), local(20, 9), local(21, 9), local(22, 9), local(23, 9), local(24, 11),
+		christmas,
+		local(26, 9), local(27, 9), local(28, 9), local(29, 9), local(30, 9), local(31, 11),
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i].Before(expected[j]) })
+
+	now := local(19, 0)
+	for _, e := range expected {
+		now = s.Next(now)
+		c.Check(now, check.Equals, e)
+	}
+
+	c.Check(ScheduleMatches(s, local(24, 11)), check.Equals, true)
+	c.Check(ScheduleMatches(s, local(24, 9)), check.Equals, false)
+	c.Check(ScheduleMatches(s, local(25, 11)), check.Equals, local(25, 11).Equal(christmas))
+}
+
+func (suite *MySuite) TestUnion(c *check.C) {
+	s, err := ParseSchedule("*/1 9:00 UTC | 12/25 0:00 UTC | 2016/1/1 12:30 UTC")
+	c.Assert(err, check.IsNil)
+
+	utc := func(month, day, hour, minute int) time.Time {
+		return time.Date(2016, time.Month(month), day, hour, minute, 0, 0, time.UTC)
+	}
+
+	c.Check(s.Next(utc(1, 1, 0, 0)), check.Equals, utc(1, 1, 9, 0))
+	c.Check(s.Next(utc(1, 1, 9, 0)), check.Equals, utc(1, 1, 12, 30))
+	c.Check(s.Next(utc(1, 1, 12, 30)), check.Equals, utc(2, 1, 9, 0))
+	c.Check(s.Next(utc(12, 1, 9, 0)), check.Equals, utc(12, 25, 0, 0))
+
+	c.Check(s.Prev(utc(1, 1, 12, 29)), check.Equals, utc(1, 1, 9, 0))
+	c.Check(s.Prev(utc(1, 1, 12, 30)), check.Equals, utc(1, 1, 12, 30))
+	c.Check(s.Prev(utc(12, 31, 0, 0)), check.Equals, utc(12, 25, 0, 0))
+
+	c.Check(ScheduleMatches(s, utc(12, 25, 0, 0)), check.Equals, true)
+	c.Check(ScheduleMatches(s, utc(3, 1, 9, 0)), check.Equals, true)
+	c.Check(ScheduleMatches(s, utc(3, 2, 9, 0)), check.Equals, false)
+
+	// Members which match the same time only match once.
+	s, err = ParseSchedule("*:0 UTC | *:0,30 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(ScheduleCount(s, utc(1, 1, 0, 0), utc(1, 2, 0, 0)), check.Equals, 48)
+
+	// A union of nothing never matches.
+	u := NewUnion()
+	c.Check(u.Next(utc(1, 1, 0, 0)), check.Equals, UNKNOWN)
+	c.Check(u.Prev(utc(1, 1, 0, 0)), check.Equals, UNKNOWN)
+}
+
+func (suite *MySuite) TestUnionTimezones(c *check.C) {
+	s, err := ParseSchedule("9:00 America/New_York | 9:00 Europe/London | 2015/1/1 0:00 UTC")
+	c.Assert(err, check.IsNil)
+
+	ny, err := time.LoadLocation("America/New_York")
+	c.Assert(err, check.IsNil)
+
+	// Results are in the timezone of now.
+	now := time.Date(2016, 3, 1, 0, 0, 0, 0, ny)
+	next := s.Next(now)
+	c.Check(next, check.Equals, time.Date(2016, 3, 1, 4, 0, 0, 0, ny))
+
+	next = s.Next(next)
+	c.Check(next, check.Equals, time.Date(2016, 3, 1, 9, 0, 0, 0, ny))
+
+	// Between March 13th and 27th, the US has changed to daylight saving
+	// time, and the UK hasn't.
+	now = time.Date(2016, 3, 15, 0, 0, 0, 0, ny)
+	c.Check(s.Next(now), check.Equals, time.Date(2016, 3, 15, 5, 0, 0, 0, ny))
+	c.Check(s.Prev(now), check.Equals, time.Date(2016, 3, 14, 9, 0, 0, 0, ny))
+
+	// The UTC member has no matches after 2015, but still wins before then.
+	c.Check(s.Prev(time.Date(2015, 1, 1, 1, 0, 0, 0, time.UTC)), check.Equals, time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC))
+}
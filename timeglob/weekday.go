@@ -0,0 +1,216 @@
+package timeglob
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Weekday names for globs, which may be abbreviated to their first 3 letters.
+var weekdayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// Globs with weekday restrictions search year wildcards this many years
+// ahead, or back. Dates fall on the same weekday every 28 years, except
+// across century years which aren't leap years, so this covers rare
+// combinations like February 29th on a Monday.
+const WEEKDAY_YEAR_SEARCH_DEPTH = 40
+
+// Day rules which can't be written as lists of values, from cron and other
+// formats.
+type dayRules struct {
+	// Weekdays which only match a single occurrence in the month, like the
+	// third Friday.
+	nth []nthWeekday
+
+	// Match the weekday (Monday to Friday) nearest each of these days,
+	// without leaving the month, as cron's W does. Negative days count back
+	// from the end of the month.
+	nearest []int
+
+	// Match days which match the day OR the weekday, as cron does when
+	// neither field starts with "*". Otherwise both must match.
+	or bool
+}
+
+// A single occurrence of a weekday in a month. n counts from 1, or back from
+// -1 for the last one.
+type nthWeekday struct {
+	weekday, n int
+}
+
+func daysIn(year, month int) int {
+	// The number of days in a month.
+	return time.Date(year, time.Month(month)+1, 0, 12, 0, 0, 0, time.UTC).Day()
+}
+
+func dayOfMonth(day, last int) int {
+	// Resolve a day which may count back from the end of a month with last
+	// days. Returns 0 if the day isn't in the month.
+
+	if day < 0 {
+		day = last + 1 + day
+	}
+	if day < 1 || day > last {
+		return 0
+	}
+	return day
+}
+
+func nearestWeekday(year, month, day int) int {
+	// The day of the month of the weekday (Monday to Friday) nearest day,
+	// without leaving the month. Returns 0 if the day isn't in the month.
+
+	last := daysIn(year, month)
+	day = dayOfMonth(day, last)
+	if day == 0 {
+		return 0
+	}
+
+	switch time.Date(year, time.Month(month), day, 12, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+func (tg *TimeGlob) listsDays() bool {
+	// Does the day list hold every day which might match? If not, every day
+	// of the month is checked with matchesDay.
+
+	if hasNegative(tg.day) {
+		return false
+	}
+	return tg.rules == nil || (!tg.rules.or && tg.rules.nearest == nil)
+}
+
+func hasNegative(values []int) bool {
+	// Do any values count back from the end?
+	for _, v := range values {
+		if v < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (tg *TimeGlob) plainDays() bool {
+	// Does the glob only restrict days with a list of days of the month?
+	return tg.weekday == nil && tg.rules == nil && !hasNegative(tg.day)
+}
+
+func (tg *TimeGlob) yearSearchDepth() int {
+	// How many years to search through year wildcards.
+
+	if tg.weekday != nil || tg.rules != nil {
+		return WEEKDAY_YEAR_SEARCH_DEPTH
+	}
+	return YEAR_SEARCH_DEPTH
+}
+
+func (tg *TimeGlob) matchesDay(year, month, day int) bool {
+	// Does a valid date match the day, weekday and day rules of the glob?
+	// The year and month are checked separately.
+
+	if tg.plainDays() {
+		return inValues(tg.day, day)
+	}
+
+	last := daysIn(year, month)
+	weekday := int(time.Date(year, time.Month(month), day, 12, 0, 0, 0, time.UTC).Weekday())
+
+	rules := tg.rules
+	if rules == nil {
+		rules = &dayRules{}
+	}
+
+	dayRestricted := tg.day != nil || rules.nearest != nil
+	dayOk := !dayRestricted
+	for _, d := range tg.day {
+		dayOk = dayOk || dayOfMonth(d, last) == day
+	}
+	for _, d := range rules.nearest {
+		dayOk = dayOk || nearestWeekday(year, month, d) == day
+	}
+
+	weekdayRestricted := tg.weekday != nil || rules.nth != nil
+	weekdayOk := tg.weekday != nil && inValues(tg.weekday, weekday)
+	for _, nth := range rules.nth {
+		if nth.weekday != weekday {
+			continue
+		}
+		if (nth.n > 0 && (day-1)/7+1 == nth.n) || (nth.n < 0 && (last-day)/7+1 == -nth.n) {
+			weekdayOk = true
+		}
+	}
+	if !weekdayRestricted {
+		weekdayOk = true
+	}
+
+	if rules.or && dayRestricted && weekdayRestricted {
+		return dayOk || weekdayOk
+	}
+	return dayOk && weekdayOk
+}
+
+func weekdayValue(name string) int {
+	// The weekday of a name, which may be abbreviated to 3 letters, or -1.
+
+	for i, day := range weekdayNames {
+		if strings.EqualFold(name, day) || strings.EqualFold(name, day[:3]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (tg *TimeGlob) parseWeekday(glob string) bool {
+	// Parse a list of weekdays and ranges, such as "Mon-Fri,Sun". Ranges may
+	// wrap around the end of the week, so "Fri-Mon" is Friday to Monday.
+
+	re := regexp.MustCompile(`^[A-Za-z]+(-[A-Za-z]+)?(,[A-Za-z]+(-[A-Za-z]+)?)*$`)
+	if !re.MatchString(glob) {
+		return false
+	}
+
+	values := map[int]bool{}
+	for _, part := range strings.Split(glob, ",") {
+		bounds := strings.Split(part, "-")
+		low, high := weekdayValue(bounds[0]), weekdayValue(bounds[len(bounds)-1])
+		if low < 0 || high < 0 {
+			return false
+		}
+		for d := low; ; d = (d + 1) % 7 {
+			values[d] = true
+			if d == high {
+				break
+			}
+		}
+	}
+
+	tg.weekday = weekdayList(values)
+	return true
+}
+
+func weekdayList(values map[int]bool) []int {
+	// Convert a set of weekdays to a sorted list, or nil for the whole week.
+
+	if len(values) == 7 {
+		return nil
+	}
+
+	result := []int{}
+	for d := 0; d < 7; d++ {
+		if values[d] {
+			result = append(result, d)
+		}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package timeglob
+
+import (
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestWeekdays(c *check.C) {
+	tg, err := Parse("Mon-Fri 9:00 UTC")
+	c.Assert(err, check.IsNil)
+
+	utc := func(month, day, hour int) time.Time {
+		return time.Date(2016, time.Month(month), day, hour, 0, 0, 0, time.UTC)
+	}
+
+	// January 1st 2016 was a Friday.
+	c.Check(tg.NextN(utc(1, 1, 0), 4), check.DeepEquals, []time.Time{
+		utc(1, 1, 9), utc(1, 4, 9), utc(1, 5, 9), utc(1, 6, 9),
+	})
+	c.Check(tg.Next(utc(1, 1, 9)), check.Equals, utc(1, 4, 9))
+	c.Check(tg.Prev(utc(1, 4, 8)), check.Equals, utc(1, 1, 9))
+	c.Check(tg.Between(utc(1, 2, 0), utc(1, 6, 0)), check.DeepEquals, []time.Time{utc(1, 4, 9), utc(1, 5, 9)})
+	c.Check(tg.Count(utc(1, 1, 0), utc(2, 1, 0)), check.Equals, 21)
+	c.Check(tg.Matches(utc(1, 4, 9)), check.Equals, true)
+	c.Check(tg.Matches(utc(1, 3, 9)), check.Equals, false)
+	validateNthMatchesNextPrev(c, tg, utc(1, 1, 0), 12)
+
+	// Ranges can wrap around the end of the week, and be combined with dates.
+	tg, err = Parse("Fri-Mon 12/24,25,26,27,28 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.NextN(utc(1, 1, 0), 4), check.DeepEquals, []time.Time{
+		utc(12, 24, 0), utc(12, 25, 0), utc(12, 26, 0), time.Date(2017, 12, 24, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func (suite *MySuite) TestWeekdaysRare(c *check.C) {
+	// February 29th falls on a Monday every 28 years, which is further than
+	// the usual year search.
+	tg, err := Parse("Mon 2/29 UTC")
+	c.Assert(err, check.IsNil)
+
+	now := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	c.Check(tg.Next(now), check.Equals, time.Date(2044, 2, 29, 0, 0, 0, 0, time.UTC))
+	c.Check(tg.Prev(now), check.Equals, time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC))
+	c.Check(tg.Nth(now, 2), check.Equals, time.Date(2072, 2, 29, 0, 0, 0, 0, time.UTC))
+
+	// February 30th never exists, on any weekday.
+	tg, err = Parse("Mon 2/30 UTC")
+	c.Assert(err, check.IsNil)
+	c.Check(tg.Next(now), check.Equals, UNKNOWN)
+}
+
+func (suite *MySuite) TestWeekdaysDaylightSavings(c *check.C) {
+	tg, err := Parse("Sun *:30 America/New_York")
+	c.Assert(err, check.IsNil)
+
+	ny := tg.location
+	now := time.Date(2016, 3, 12, 0, 0, 0, 0, ny)
+
+	// The clocks went forward at 2 AM on Sunday March 13th, so there's no
+	// 2:30.
+	c.Check(tg.NextN(now, 3), check.DeepEquals, []time.Time{
+		time.Date(2016, 3, 13, 0, 30, 0, 0, ny),
+		time.Date(2016, 3, 13, 1, 30, 0, 0, ny),
+		time.Date(2016, 3, 13, 3, 30, 0, 0, ny),
+	})
+	c.Check(tg.Count(now, now.AddDate(0, 0, 7)), check.Equals, 23)
+}